@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Credential holds the login and password for Basic authentication.
+type Credential struct {
+	Login    string
+	Password string
+}
+
+// applyRequestOptions sets the User-Agent, the extra headers and the
+// credentials configured for the request's host.
+//
+// Credential precedence:
+// - A Netrc entry naming the host, the most specific credentials.
+// - The BearerToken.
+// - The BasicAuth credentials.
+// - The Netrc `default` entry, used when no explicit credentials are given.
+//
+// Notes:
+// - Credentials are only ever written to the request, never logged.
+// - With Options.AuthHosts, only a Netrc entry naming the host is sent to the other hosts.
+func (d *Downloader) applyRequestOptions(req *http.Request) {
+	if d.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", d.opts.UserAgent)
+	}
+	for _, header := range d.opts.Headers {
+		name, value, _ := strings.Cut(header, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	host := req.URL.Hostname()
+	if cred, ok := d.opts.Netrc[host]; ok {
+		req.SetBasicAuth(cred.Login, cred.Password)
+	} else if len(d.opts.AuthHosts) > 0 && !matchesNoProxy(host, d.opts.AuthHosts) {
+		return // not a host the credentials are meant for
+	} else if d.opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+d.opts.BearerToken)
+	} else if d.opts.BasicAuth != nil {
+		req.SetBasicAuth(d.opts.BasicAuth.Login, d.opts.BasicAuth.Password)
+	} else if cred, ok := d.opts.Netrc[""]; ok {
+		req.SetBasicAuth(cred.Login, cred.Password)
+	}
+}
+
+// ParseBasicAuth splits a `user[:password]` value.
+func ParseBasicAuth(value string) *Credential {
+	if value == "" {
+		return nil
+	}
+	login, password, _ := strings.Cut(value, ":")
+	return &Credential{Login: login, Password: password}
+}
+
+// LoadBearerToken reads the bearer token from a file or an environment variable.
+// The file takes precedence when both are given.
+func LoadBearerToken(tokenFile string, tokenEnv string) (string, error) {
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading bearer token file: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if tokenEnv != "" {
+		token, ok := os.LookupEnv(tokenEnv)
+		if !ok {
+			return "", fmt.Errorf("bearer token environment variable %s is not set", tokenEnv)
+		}
+		return strings.TrimSpace(token), nil
+	}
+	return "", nil
+}
+
+// LoadNetrc parses a .netrc file into per-host credentials.
+// The `default` entry is stored under the empty host name; `macdef` blocks are ignored.
+func LoadNetrc(filePath string) (map[string]Credential, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	credentials := make(map[string]Credential)
+	var host string
+	var cred Credential
+	inEntry := false
+	flush := func() {
+		if inEntry {
+			credentials[host] = cred
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		switch token := scanner.Text(); token {
+		case "machine", "default":
+			flush()
+			host, cred, inEntry = "", Credential{}, true
+			if token == "machine" && scanner.Scan() {
+				host = scanner.Text()
+			}
+		case "login":
+			if scanner.Scan() {
+				cred.Login = scanner.Text()
+			}
+		case "password":
+			if scanner.Scan() {
+				cred.Password = scanner.Text()
+			}
+		case "account":
+			scanner.Scan()
+		case "macdef":
+			flush()
+			inEntry = false
+		}
+	}
+	flush()
+	return credentials, scanner.Err()
+}
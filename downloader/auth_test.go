@@ -0,0 +1,97 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test .netrc parsing with machine and default entries
+func TestLoadNetrc(t *testing.T) {
+	netrcPath := filepath.Join(t.TempDir(), "netrc")
+	content := "machine example.com login alice password secret\ndefault login anon password guest\n"
+	if err := os.WriteFile(netrcPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write netrc file: %v", err)
+	}
+
+	credentials, err := LoadNetrc(netrcPath)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if cred := credentials["example.com"]; cred.Login != "alice" || cred.Password != "secret" {
+		t.Errorf("Unexpected credentials for example.com: %+v", cred)
+	}
+	if cred := credentials[""]; cred.Login != "anon" || cred.Password != "guest" {
+		t.Errorf("Unexpected default credentials: %+v", cred)
+	}
+}
+
+// Test User-Agent, extra headers and bearer token are sent with the request
+func TestDownloadURL_RequestOptions(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	d := newTestDownloader(t, Options{UserAgent: "test-agent", Headers: []string{"X-Team: data"}, BearerToken: "token"})
+	if _, err := d.downloadURL(context.Background(), server.URL, &Metrics{}); err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if got.Get("User-Agent") != "test-agent" {
+		t.Errorf("Expected User-Agent test-agent, got %q", got.Get("User-Agent"))
+	}
+	if got.Get("X-Team") != "data" {
+		t.Errorf("Expected X-Team header data, got %q", got.Get("X-Team"))
+	}
+	if got.Get("Authorization") != "Bearer token" {
+		t.Errorf("Expected bearer Authorization header, got %q", got.Get("Authorization"))
+	}
+}
+
+// Test the credentials are only sent to the hosts of AuthHosts
+func TestApplyRequestOptions_AuthHosts(t *testing.T) {
+	d := newTestDownloader(t, Options{
+		BearerToken: "token",
+		Netrc:       map[string]Credential{"files.example.org": {Login: "files", Password: "secret"}},
+		AuthHosts:   []string{"api.example.com"},
+	})
+	tests := map[string]string{
+		"https://api.example.com/a":    "Bearer token",
+		"https://v2.api.example.com/a": "Bearer token",
+		"https://files.example.org/a":  "Basic ZmlsZXM6c2VjcmV0",
+		"https://attacker.example/a":   "",
+	}
+	for url, expected := range tests {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		d.applyRequestOptions(req)
+		if got := req.Header.Get("Authorization"); got != expected {
+			t.Errorf("%s: expected Authorization %q, got %q", url, expected, got)
+		}
+	}
+}
+
+// Test a netrc entry naming the host comes first, then the explicit credentials, then the netrc default entry
+func TestApplyRequestOptions_Precedence(t *testing.T) {
+	netrc := map[string]Credential{"files.example.org": {Login: "files", Password: "secret"}, "": {Login: "anon", Password: "guest"}}
+	tests := []struct {
+		opts     Options
+		url      string
+		expected string
+	}{
+		{Options{BearerToken: "token", Netrc: netrc}, "https://files.example.org/a", "Basic ZmlsZXM6c2VjcmV0"},
+		{Options{BearerToken: "token", Netrc: netrc}, "https://api.example.com/a", "Bearer token"},
+		{Options{BasicAuth: &Credential{Login: "user", Password: "pass"}, Netrc: netrc}, "https://api.example.com/a", "Basic dXNlcjpwYXNz"},
+		{Options{Netrc: netrc}, "https://api.example.com/a", "Basic YW5vbjpndWVzdA=="},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+		newTestDownloader(t, test.opts).applyRequestOptions(req)
+		if got := req.Header.Get("Authorization"); got != test.expected {
+			t.Errorf("%s: expected Authorization %q, got %q", test.url, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package downloader
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// NewHTTPClient builds the HTTP client used by Stage 2 from the transport options
+// and the redirect policy.
+//
+// Output:
+// - Returns a client with its own transport, so nothing is shared with http.DefaultClient.
+// - Returns an error if the proxy URL or any certificate file is invalid.
+//
+// Notes:
+// - Without a Proxy the usual HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables apply.
+// - The proxy may be http://, https:// or socks5://.
+// - Idle connections per host default to MAX_WORKERS so that workers can reuse connections.
+func NewHTTPClient(opts TransportOptions, redirects RedirectOptions) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	maxIdleConnsPerHost, keepAlive, idleConnTimeout := opts.MaxIdleConnsPerHost, opts.KeepAlive, opts.IdleConnTimeout
+	if maxIdleConnsPerHost == 0 {
+		maxIdleConnsPerHost = MAX_WORKERS
+	}
+	if keepAlive == 0 {
+		keepAlive = 30 * time.Second
+	}
+	if idleConnTimeout == 0 {
+		idleConnTimeout = 90 * time.Second
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: keepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     !opts.DisableHTTP2,
+		MaxIdleConns:          maxIdleConnsPerHost * 2,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableKeepAlives:     opts.DisableKeepAlives,
+	}
+	if opts.DisableHTTP2 {
+		// A non-nil empty map disables the automatic HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %v", err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+		}
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if matchesNoProxy(req.URL.Hostname(), opts.NoProxy) {
+				return nil, nil
+			}
+			return proxy, nil
+		}
+	}
+
+	return &http.Client{Transport: transport, CheckRedirect: redirects.check}, nil
+}
+
+// check applies the redirect policy to every hop.
+//
+// Notes:
+// - With NoFollow redirects are not followed and the 3xx response is returned as is.
+// - SameHost rejects hops to a host other than the original one.
+// - ForbidDowngrade rejects hops from https to http.
+func (r RedirectOptions) check(req *http.Request, via []*http.Request) error {
+	if r.NoFollow {
+		return http.ErrUseLastResponse
+	}
+	maxRedirects := r.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = 10
+	}
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	previous := via[len(via)-1]
+	if r.SameHost && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+		return fmt.Errorf("redirect to another host %s is not allowed", req.URL.Host)
+	}
+	if r.ForbidDowngrade && previous.URL.Scheme == "https" && req.URL.Scheme == "http" {
+		return fmt.Errorf("redirect from https to http is not allowed: %s", req.URL)
+	}
+	return nil
+}
+
+// rejectPrivateRedirects returns a copy of client that also refuses redirects
+// to the addresses rejected by URLOptions.RejectPrivate, once its own policy
+// accepted the hop. client is not modified.
+func rejectPrivateRedirects(client *http.Client) *http.Client {
+	checked := *client
+	policy := client.CheckRedirect
+	checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if policy != nil {
+			if err := policy(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects") // the default policy of http.Client
+		}
+		host := strings.ToLower(req.URL.Hostname())
+		if isPrivateHost(host, net.ParseIP(host)) {
+			return fmt.Errorf("redirect to private address %s is not allowed", req.URL.Host)
+		}
+		return nil
+	}
+	return &checked
+}
+
+// redirectChain returns the URLs visited to obtain resp, starting with the
+// original request and ending with the final one. It is nil when no redirect happened.
+func redirectChain(resp *http.Response) []string {
+	chain := visitedURLs(resp)
+	if len(chain) < 2 {
+		return nil
+	}
+	return chain
+}
+
+// refusedRedirectChain returns the redirect chain of a request whose redirect
+// was refused by the policy, see RedirectOptions.check: the URLs visited up to
+// the last redirect response resp, followed by the refused target named by err.
+func refusedRedirectChain(resp *http.Response, err error) []string {
+	var urlErr *url.Error
+	if resp == nil || !errors.As(err, &urlErr) {
+		return nil
+	}
+	target := urlErr.URL // the Location header, possibly relative
+	if resolved, err := resp.Request.URL.Parse(target); err == nil {
+		target = resolved.String()
+	}
+	return append(visitedURLs(resp), target)
+}
+
+// visitedURLs returns the URLs requested to obtain resp, oldest first.
+func visitedURLs(resp *http.Response) []string {
+	var chain []string
+	for req := resp.Request; req != nil; {
+		chain = append([]string{req.URL.String()}, chain...)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	return chain
+}
+
+// newTLSConfig loads the custom CA bundle and the client certificate for mTLS.
+func newTLSConfig(opts TransportOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+
+	if opts.CACertFile != "" {
+		pem, err := os.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// matchesNoProxy reports whether host bypasses the proxy.
+// An entry matches the host itself and all of its subdomains; "*" matches every host.
+func matchesNoProxy(host string, noProxyList []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxyList {
+		entry = strings.ToLower(strings.TrimPrefix(entry, "."))
+		if entry == "*" || host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package downloader
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test the client trusts a server signed by the custom CA bundle
+func TestNewHTTPClient_CACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure content"))
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+
+	d, err := New(Options{Transport: TransportOptions{CACertFile: caPath}})
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+
+	result, err := d.downloadURL(context.Background(), server.URL, &Metrics{})
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if string(result.Content) != "secure content" {
+		t.Errorf("Expected %q, got %q", "secure content", string(result.Content))
+	}
+}
+
+// Test invalid proxy schemes are rejected
+func TestNewHTTPClient_InvalidProxy(t *testing.T) {
+	if _, err := NewHTTPClient(TransportOptions{Proxy: "ftp://proxy.local:21"}, RedirectOptions{}); err == nil {
+		t.Errorf("Expected error for ftp proxy, but got nil")
+	}
+}
+
+// Test no-proxy matching of hosts and subdomains
+func TestMatchesNoProxy(t *testing.T) {
+	noProxyList := []string{"internal.local", ".corp.example"}
+	tests := map[string]bool{
+		"internal.local":     true,
+		"api.internal.local": true,
+		"corp.example":       true,
+		"www.corp.example":   true,
+		"example.com":        false,
+		"notinternal.local":  false,
+	}
+	for host, expected := range tests {
+		if got := matchesNoProxy(host, noProxyList); got != expected {
+			t.Errorf("matchesNoProxy(%q) = %v, expected %v", host, got, expected)
+		}
+	}
+}
+
+// Test the redirect chain is recorded and the hop limit is enforced
+func TestDownloadURL_Redirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/start", http.RedirectHandler("/middle", http.StatusFound))
+	mux.Handle("/middle", http.RedirectHandler("/final", http.StatusFound))
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("final"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	d := newTestDownloader(t, Options{HTTPClient: &http.Client{CheckRedirect: RedirectOptions{MaxRedirects: 10}.check}})
+	result, err := d.downloadURL(context.Background(), server.URL+"/start", &Metrics{})
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	expected := []string{server.URL + "/start", server.URL + "/middle", server.URL + "/final"}
+	if len(result.Redirects) != len(expected) {
+		t.Fatalf("Expected redirect chain %v, got %v", expected, result.Redirects)
+	}
+	for i := range expected {
+		if result.Redirects[i] != expected[i] {
+			t.Errorf("Expected hop %d to be %s, got %s", i, expected[i], result.Redirects[i])
+		}
+	}
+
+	d = newTestDownloader(t, Options{Redirects: RedirectOptions{MaxRedirects: 1}})
+	result, err = d.downloadURL(context.Background(), server.URL+"/start", &Metrics{})
+	if err == nil {
+		t.Errorf("Expected error when exceeding max redirects, but got nil")
+	}
+	if strings.Join(result.Redirects, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected the refused chain %v, got %v", expected, result.Redirects)
+	}
+
+	// The refused chain is recorded with the failure in the manifest
+	manifestFile := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := OpenManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	d = newTestDownloader(t, Options{Redirects: RedirectOptions{MaxRedirects: 1}, Manifest: manifest})
+	d.Run(context.Background(), SliceSource{server.URL + "/start"}, &DirSink{Dir: t.TempDir()})
+	manifest.Close()
+	entries, err := ReadManifest(manifestFile)
+	if err != nil || len(entries) != 1 || entries[0].Outcome != OutcomeFailed || len(entries[0].Redirects) != 3 {
+		t.Errorf("Expected a failed entry with the refused chain, got %+v (%v)", entries, err)
+	}
+}
+
+// Test redirects to private addresses are refused with RejectPrivate
+func TestDownloadURL_RedirectToPrivate(t *testing.T) {
+	server := httptest.NewServer(http.RedirectHandler("http://10.0.0.1/admin", http.StatusFound))
+	defer server.Close()
+
+	client := &http.Client{CheckRedirect: RedirectOptions{MaxRedirects: 5}.check}
+	d := newTestDownloader(t, Options{HTTPClient: client, URLs: URLOptions{RejectPrivate: true}})
+	result, err := d.downloadURL(context.Background(), server.URL, &Metrics{})
+	if err == nil || !strings.Contains(err.Error(), "private address") {
+		t.Errorf("Expected the redirect to a private address to be refused, got %v", err)
+	}
+	if len(result.Redirects) != 2 || result.Redirects[1] != "http://10.0.0.1/admin" {
+		t.Errorf("Expected the refused chain to end with the private target, got %v", result.Redirects)
+	}
+	if d.client == client || client.CheckRedirect == nil {
+		t.Errorf("Expected the client of Options.HTTPClient to be left as is")
+	}
+}
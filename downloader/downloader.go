@@ -0,0 +1,543 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Downloader runs the three stage pipeline: a Source feeds URLs (Stage 1), up to
+// Options.Workers downloads run concurrently (Stage 2) and a Sink stores every
+// body (Stage 3). With Options.LookAhead, a scheduler between Stages 1 and 2
+// dispatches the URLs of higher priority first, and with Options.RespectRobots
+// the URLs of a host waiting for its Crawl-delay are held back. It holds no
+// global state, several downloaders may run in the same process and a
+// downloader may run several times.
+type Downloader struct {
+	opts   Options
+	client *http.Client
+	log    zerolog.Logger
+	hooks  Hooks
+	robots *robotsCache // nil unless Options.RespectRobots
+
+	robotsClient *http.Client // fetches robots.txt, see newRobotsClient
+}
+
+// Download is a downloaded body handed to the Sink.
+type Download struct {
+	URL         string // URL as read from the source
+	Input       Input  // row the URL was read from
+	Scheme      string // scheme of the request, http after an HTTP fallback (see Options.HTTPFallback)
+	FetchedURL  string // URL requested when it differs from URL, the http one after an HTTP fallback
+	Content     []byte
+	ContentType string
+	Redirects   []string      // redirect chain from the requested URL to the final one
+	TTFB        time.Duration // time from sending the request to the first response byte
+	Duration    time.Duration // time from sending the request to the end of the body
+}
+
+// job is a URL read by Stage 1 with the row it was read from, carried to the
+// download (Stage 2) and then, as part of its Download, to the sink (Stage 3).
+type job struct {
+	Input
+	url      string // normalized URL
+	priority int    // higher is dispatched first with Options.LookAhead, see schedule
+	fallback bool   // read without a scheme and eligible for Options.HTTPFallback
+}
+
+// inputURL identifies a normalized URL read from an input file, the URLs
+// read again from the same input are duplicates.
+type inputURL struct {
+	file string
+	url  string
+}
+
+// skipError reports a response rejected by the configured size or Content-Type
+// limits. It is recorded as a skipped outcome rather than a failure.
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+// New validates opts and returns a Downloader.
+//
+// Output:
+// - Returns an error if an option is invalid or the HTTP client cannot be built (see NewHTTPClient).
+func New(opts Options) (*Downloader, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Workers == 0 {
+		opts.Workers = MAX_WORKERS
+	}
+
+	d := &Downloader{opts: opts, client: opts.HTTPClient, log: opts.Logger, hooks: opts.Hooks}
+	if d.hooks == nil {
+		d.hooks = NoHooks{}
+	}
+	if d.client == nil {
+		if opts.Transport.MaxIdleConnsPerHost == 0 {
+			opts.Transport.MaxIdleConnsPerHost = opts.Workers
+		}
+		client, err := NewHTTPClient(opts.Transport, opts.Redirects)
+		if err != nil {
+			return nil, err
+		}
+		d.client = client
+	}
+	if opts.RespectRobots {
+		d.robotsClient = newRobotsClient(d.client, opts.URLs.RejectPrivate)
+		d.robots = newRobotsCache(d.fetchRobots)
+	}
+	if opts.URLs.RejectPrivate {
+		d.client = rejectPrivateRedirects(d.client)
+	}
+	return d, nil
+}
+
+// Run downloads every URL of source and hands the bodies to sink.
+//
+// Input:
+// - ctx: Context for graceful shutdown; once it is done no new download starts.
+// - source: Provides the URLs (Stage 1).
+// - sink: Stores the downloaded bodies (Stage 3).
+//
+// Output:
+// - Returns the Result of the run, with the Metrics it was recorded in.
+// - Returns an error if source failed, e.g. its file could not be opened; the Result covers the URLs read until then.
+//
+// Notes:
+// - Sources and sinks can log through zerolog.Ctx(ctx), which holds Options.Logger.
+// - Run returns once every stage has stopped, no goroutine outlives it.
+func (d *Downloader) Run(ctx context.Context, source Source, sink Sink) (Result, error) {
+	metrics := d.opts.Metrics
+	if metrics == nil {
+		metrics = &Metrics{}
+	}
+	metrics.start()
+	ctx = d.log.WithContext(ctx)
+
+	read := make(chan job, d.opts.Workers) // Stage 1 output
+	urls := read                           // Stage 2 input
+	var scheduled, held atomic.Int64
+	if d.opts.LookAhead > 0 {
+		urls = make(chan job) // dispatched one at a time, in priority order
+	}
+	paced := urls // Stage 2 input with Options.RespectRobots
+	if d.robots != nil {
+		paced = make(chan job) // dispatched one at a time, once their host is due
+	}
+	downloads := make(chan Download, d.opts.Workers)
+	metrics.setQueues(func() map[string]int {
+		queues := map[string]int{"urls": len(read), "contents": len(downloads)}
+		if d.opts.LookAhead > 0 {
+			queues["scheduled"] = int(scheduled.Load())
+		}
+		if d.robots != nil {
+			queues["held"] = int(held.Load())
+		}
+		return queues
+	})
+	var wg sync.WaitGroup
+
+	// Stage 1: Read URLs
+	var sourceErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(read)
+		d.log.Info().Msg("Stage-1 Started Reading URLs")
+		sourceErr = d.readSource(ctx, source, read, metrics)
+		d.log.Info().Msg("Stage-1 Completed ")
+	}()
+
+	// Dispatch the URLs read by Stage 1 by priority
+	if d.opts.LookAhead > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.schedule(ctx, read, urls, &scheduled)
+		}()
+	}
+
+	// Hold back the URLs of the hosts waiting for their Crawl-delay
+	if d.robots != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.pace(ctx, urls, paced, &held)
+		}()
+	}
+
+	// Stage 2: Download URLs
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.log.Info().Msg("Stage-2 Started  download URLS")
+		d.downloadURLs(ctx, paced, downloads, metrics, &wg)
+		d.log.Info().Msg("Stage-2 Completed ")
+	}()
+
+	// Close downloads once all download goroutines are done
+	stagesDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(downloads)
+		close(stagesDone)
+	}()
+
+	// Stage 3: Persist Contents (Single Goroutine)
+	d.log.Info().Msg("Stage-3 Started  Persistent")
+	d.persistContent(ctx, downloads, sink, metrics)
+	d.log.Info().Msg("Stage-3 Completed ")
+	<-stagesDone
+	metrics.finish()
+
+	if sourceErr != nil {
+		d.log.Error().Msgf("Error reading URLs: %v", sourceErr)
+	}
+	return newResult(metrics, ctx.Err() != nil, d.opts.MaxFailureRatio), sourceErr
+}
+
+// readSource runs source and forwards its URLs to urls, calling Hooks.OnQueued
+// (preceded by Hooks.OnRetry for a RetrySource) for every one of them.
+//
+// Notes:
+// - URLs are normalized (see NormalizeURL); invalid rows and rejected URLs are logged and recorded in Options.Rejects instead, with their line number for a CSVSource.
+// - A URL read again from the same input after its first occurrence, once normalized, is logged and skipped, as counted by Plan; every input of a MultiSource gets its own outputs, so each one downloads the URLs it lists.
+// - With Options.HTTPFallback, URLs read without a scheme are marked for the fallback to http.
+// - Counts the queued, duplicate and rejected URLs of every input in metrics.
+func (d *Downloader) readSource(ctx context.Context, source Source, urls chan<- job, metrics *Metrics) error {
+	_, retry := source.(RetrySource)
+	seen := make(map[inputURL]bool)
+	return readRows(ctx, source, func(row sourceRow) bool {
+		inputStats := metrics.Input(row.File)
+		url, rowErr := normalizeRow(row, d.opts.URLs)
+		if rowErr != nil {
+			inputStats.Rejected.Add(1)
+			d.reject(*rowErr)
+			return true
+		}
+		if seen[inputURL{row.File, url}] {
+			d.log.Info().Stringer("input", row.Input).Msgf("Skipping duplicate URL %s", url)
+			inputStats.Duplicates.Add(1)
+			return true
+		}
+		seen[inputURL{row.File, url}] = true
+		inputStats.URLs.Add(1)
+
+		if retry {
+			d.hooks.OnRetry(url)
+		}
+		d.hooks.OnQueued(url)
+		fallback := d.opts.HTTPFallback && !hasScheme(strings.TrimSpace(row.url)) && strings.HasPrefix(url, "https://")
+		select {
+		case urls <- job{Input: row.Input, url: url, priority: row.priority, fallback: fallback}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// downloadURLs concurrently downloads content from URLs received via a channel.
+//
+// Input:
+// - ctx: Context for graceful shutdown and cancellation handling.
+// - urls: A channel that provides the jobs to download, URLs with their input rows.
+// - downloads: A channel to send the downloaded content for persistence.
+// - metrics: A pointer to the Metrics struct for tracking success and failure counts.
+// - wg: WaitGroup to synchronize goroutines.
+//
+// Output:
+// - Downloads content from URLs and sends results to downloads.
+// - Updates metrics for read, failed, skipped and robots.txt blocked URLs, in total, per host and per input; successes are counted by Stage 3 once stored.
+// - Records the scheme of the request in the manifest, http for the URLs downloaded after an HTTP fallback, with the http URL as fetched_url.
+// - Logs and records every outcome with the input row of the URL.
+// - Fails the bodies that don't match the SHA-256 given by their input row as FailureChecksum.
+// - Calls Hooks.OnStart, OnSuccess and OnFailure.
+// - Ensures a maximum of Options.Workers concurrent downloads, and of Options.Pool downloads across the Downloaders sharing it.
+//
+// Notes:
+// - Uses a semaphore (channel) to limit concurrent downloads; a slot is acquired before the next URL is received.
+// - With Options.RespectRobots, URLs disallowed by robots.txt are blocked; pace spaces the requests to a host by its Crawl-delay.
+// - Supports graceful shutdown by listening to ctx.Done().
+// - Ensures goroutine cleanup with wg.Done().
+func (d *Downloader) downloadURLs(ctx context.Context, urls <-chan job, downloads chan<- Download, metrics *Metrics, wg *sync.WaitGroup) {
+	semaphore := make(chan struct{}, d.opts.Workers) // Limit to Options.Workers concurrent downloads
+
+	for {
+		// Acquire a semaphore slot, then process the next URL received from urls
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done(): // Handle shutdown scenario
+			d.log.Error().Msgf("Stage 2: Context canceled / Shutdown initiated. Stopping new downloads.")
+			return
+		}
+		var item job
+		select {
+		case next, ok := <-urls:
+			if !ok {
+				return
+			}
+			item = next
+		case <-ctx.Done():
+			d.log.Error().Msgf("Stage 2: Context canceled / Shutdown initiated. Stopping new downloads.")
+			return
+		}
+		metrics.TotalURLs.Add(1) // Update the metrics count
+
+		wg.Add(1)
+		go func(item job) {
+			defer wg.Done()                // Ensure the goroutine signals completion
+			defer func() { <-semaphore }() // Release semaphore slot
+			u := item.url
+			inputStats := metrics.Input(item.File)
+
+			// Honor robots.txt, pace already waited for the host's Crawl-delay
+			_, allowed, err := d.robots.permits(ctx, ensureScheme(u))
+			if err != nil {
+				d.log.Info().Msgf("Stage 2: Context canceled / Shutdown initiated. Skipping %s", u)
+				return
+			}
+			if !allowed {
+				d.log.Warn().Stringer("input", item.Input).Msgf("Blocked by robots.txt: %s", u)
+				metrics.AddBlocked()
+				metrics.Host(hostOf(ensureScheme(u))).Blocked.Add(1)
+				inputStats.Blocked.Add(1)
+				d.record(ManifestEntry{URL: u, Input: item.ref(), Outcome: OutcomeBlocked})
+				return
+			}
+
+			// Wait for a slot of the worker pool shared with other Downloaders
+			if !d.opts.Pool.acquire(ctx) {
+				d.log.Info().Msgf("Stage 2: Context canceled / Shutdown initiated. Skipping %s", u)
+				return
+			}
+			hostStats := metrics.Host(hostOf(ensureScheme(u)))
+			hostStats.Requests.Add(1)
+
+			d.hooks.OnStart(u)
+			start := time.Now() // Record start time for metrics
+			metrics.InFlight.Add(1)
+			download, err := d.fetch(ctx, item, metrics)
+			if err == nil {
+				err = checkSHA256(download.Content, item.SHA256)
+			}
+			metrics.InFlight.Add(-1)
+			d.opts.Pool.release()
+			download.URL, download.Input = u, item.Input
+			var skipErr *skipError
+			if errors.As(err, &skipErr) {
+				d.log.Warn().Stringer("input", item.Input).Msgf("Skipping %s: %v", u, err)
+				metrics.AddSkipped() // Track responses rejected by the limits
+				hostStats.Skipped.Add(1)
+				inputStats.Skipped.Add(1)
+				d.record(ManifestEntry{URL: u, Input: item.ref(), Outcome: OutcomeSkipped, Scheme: download.Scheme, FetchedURL: download.FetchedURL, ContentType: download.ContentType, Redirects: download.Redirects, Error: err.Error()})
+				return
+			}
+			if err != nil {
+				class := classifyFailure(err)
+				d.log.Error().Str("class", class).Stringer("input", item.Input).Msgf("Error downloading %s: %v", u, err)
+				metrics.AddFailure(class) // Track failed downloads per failure class
+				hostStats.AddFailure(class)
+				inputStats.Failures.Add(1)
+				metrics.AddFailedURL(u, item.Input, class, err)
+				d.record(ManifestEntry{URL: u, Input: item.ref(), Outcome: OutcomeFailed, Scheme: download.Scheme, FetchedURL: download.FetchedURL, FailureClass: class, Redirects: download.Redirects, Error: err.Error()})
+				d.hooks.OnFailure(u, err)
+				return
+			}
+
+			download.Duration = time.Since(start) // Counted as a success once Stage 3 stored the body
+			d.hooks.OnSuccess(download)
+			if len(download.Redirects) > 0 {
+				d.log.Info().Strs("redirects", download.Redirects).Stringer("input", item.Input).Msgf("Followed %d redirects for %s", len(download.Redirects)-1, u)
+			}
+
+			// Send the downloaded content to Stage 3 or handle shutdown
+			select {
+			case downloads <- download:
+			case <-ctx.Done():
+				d.log.Info().Msgf("Stage 2: Context canceled / Shutdown initiated. Skipping content persistence.")
+				return
+			}
+		}(item)
+	}
+}
+
+// fetch downloads a queued URL. With Options.HTTPFallback, a URL read without
+// a scheme is requested again over http when the https request fails to
+// connect or to complete the TLS handshake; Hooks.OnRetry is called with the
+// http URL and the Download records it as FetchedURL.
+func (d *Downloader) fetch(ctx context.Context, item job, metrics *Metrics) (Download, error) {
+	download, err := d.downloadURL(ctx, item.url, metrics)
+	if err == nil || !item.fallback {
+		return download, err
+	}
+	if class := classifyFailure(err); class == FailureTLS || class == FailureConnect {
+		httpURL := "http://" + strings.TrimPrefix(item.url, "https://")
+		d.log.Warn().Str("class", class).Stringer("input", item.Input).Msgf("HTTPS failed for %s, falling back to %s: %v", item.url, httpURL, err)
+		d.hooks.OnRetry(httpURL)
+		download, err = d.downloadURL(ctx, httpURL, metrics)
+		download.FetchedURL = httpURL
+		return download, err
+	}
+	return download, err
+}
+
+// downloadURL fetches the content of a given URL using an HTTP GET request.
+//
+// Input:
+// - ctx: Context for handling timeouts or cancellations.
+// - url: The URL to download.
+// - metrics: Tracks the bytes read while the body is downloaded.
+//
+// Output:
+// - Returns a Download holding the scheme of the request, the response body, its Content-Type, the redirect chain and the time to first byte.
+// - Returns an error if the request fails or the response status is not 200 OK (see classifyFailure).
+// - Returns a *skipError if the response exceeds Options.MaxBodySize or its Content-Type is rejected.
+//
+// Notes:
+// - Uses http.NewRequestWithContext to support graceful shutdown.
+// - Applies the configured User-Agent, headers and credentials.
+// - Ensures the response body is closed properly to prevent resource leaks.
+// - The size limit is checked against Content-Length up front and enforced while reading.
+func (d *Downloader) downloadURL(ctx context.Context, url string, metrics *Metrics) (Download, error) {
+	download := Download{URL: url}
+	maxBodySize := d.opts.MaxBodySize
+
+	// Create a new HTTP GET request with context for cancellation support
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return download, err // Return error if request creation fails
+	}
+	download.Scheme = req.URL.Scheme
+	d.applyRequestOptions(req) // User-Agent, extra headers and credentials
+
+	// Measure the time to first byte of the final response
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { download.TTFB = time.Since(start) },
+	}))
+
+	// Send the HTTP request through the configured client
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// A redirect refused by the policy comes with the last redirect response, keep the chain that explains it
+		download.Redirects = refusedRedirectChain(resp, err)
+		return download, err // Return error if request execution fails
+	}
+	defer resp.Body.Close() // Ensure the response body is closed
+	download.Redirects = redirectChain(resp)
+
+	// Check for non-200 HTTP status codes
+	if resp.StatusCode != http.StatusOK {
+		return download, &httpStatusError{code: resp.StatusCode}
+	}
+
+	// Apply the Content-Type and size limits before reading the body
+	download.ContentType = resp.Header.Get("Content-Type")
+	if err := d.checkContentType(download.ContentType); err != nil {
+		return download, err
+	}
+	if maxBodySize > 0 && resp.ContentLength > maxBodySize {
+		return download, &skipError{reason: fmt.Sprintf("Content-Length %d exceeds max size %d", resp.ContentLength, maxBodySize)}
+	}
+
+	// Read the response body, never buffering more than maxBodySize+1 bytes
+	body, untrack := metrics.trackTransfer(url, resp.ContentLength, resp.Body) // per file progress
+	defer untrack()
+	if maxBodySize > 0 {
+		body = io.LimitReader(body, maxBodySize+1)
+	}
+	download.Content, err = io.ReadAll(body)
+	if err != nil {
+		return download, &bodyReadError{err: err}
+	}
+	if maxBodySize > 0 && int64(len(download.Content)) > maxBodySize {
+		download.Content = nil
+		return download, &skipError{reason: fmt.Sprintf("body exceeds max size %d", maxBodySize)}
+	}
+	return download, nil
+}
+
+// checkContentType matches the media type of a response against DenyTypes and
+// AllowTypes. A missing header is treated as application/octet-stream.
+func (d *Downloader) checkContentType(contentType string) error {
+	allowTypes, denyTypes := d.opts.AllowTypes, d.opts.DenyTypes
+	if len(allowTypes) == 0 && len(denyTypes) == 0 {
+		return nil
+	}
+	mediaType := "application/octet-stream"
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return &skipError{reason: fmt.Sprintf("invalid Content-Type %q", contentType)}
+		}
+		mediaType = parsed
+	}
+	if matchesAny(denyTypes, mediaType) {
+		return &skipError{reason: fmt.Sprintf("Content-Type %s is denied", mediaType)}
+	}
+	if len(allowTypes) > 0 && !matchesAny(allowTypes, mediaType) {
+		return &skipError{reason: fmt.Sprintf("Content-Type %s is not allowed", mediaType)}
+	}
+	return nil
+}
+
+// reject logs a row rejected by Stage 1 and records it in the rejects file of its input.
+func (d *Downloader) reject(row RowError) {
+	d.log.Warn().Msgf("Rejected %s: %s: %q", row.Input, row.Reason, row.Text)
+	rejects := d.opts.Rejects
+	if inputRejects, ok := d.opts.InputRejects[row.File]; ok {
+		rejects = inputRejects
+	}
+	if err := rejects.Record(row); err != nil {
+		d.log.Error().Msgf("Error writing rejected row %q: %v", row.Text, err)
+	}
+}
+
+// record writes entry to the manifest of its input, logging rather than failing the download on error.
+func (d *Downloader) record(entry ManifestEntry) {
+	manifest := d.opts.Manifest
+	if entry.Input != nil {
+		if inputManifest, ok := d.opts.InputManifests[entry.Input.File]; ok {
+			manifest = inputManifest
+		}
+	}
+	if err := manifest.Record(entry); err != nil {
+		d.log.Error().Msgf("Error writing manifest entry for URL: %s: %v", entry.URL, err)
+	}
+}
+
+// matchesAny reports whether value matches any of the path.Match patterns.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureScheme prefixes URLs read without a scheme with https://.
+func ensureScheme(url string) string {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "https://" + url // Default to HTTPS
+	}
+	return url
+}
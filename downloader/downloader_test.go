@@ -0,0 +1,394 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Mock HTTP server to simulate downloads
+func mockHTTPServer(response string, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+}
+
+// newTestDownloader returns a Downloader built from opts or fails the test
+func newTestDownloader(t *testing.T, opts Options) *Downloader {
+	t.Helper()
+	d, err := New(opts)
+	if err != nil {
+		t.Fatalf("Failed to create downloader: %v", err)
+	}
+	return d
+}
+
+// Test successful download
+func TestDownloadURL_Success(t *testing.T) {
+	server := mockHTTPServer("test content", http.StatusOK)
+	defer server.Close()
+	ctx := context.Background()
+	d := newTestDownloader(t, Options{})
+	result, err := d.downloadURL(ctx, server.URL, &Metrics{})
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+
+	expected := "test content"
+	if string(result.Content) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(result.Content))
+	} else {
+		t.Logf("TestDownloadURL_Success passed")
+	}
+}
+
+// Test invalid URL format
+func TestDownloadURL_InvalidURL(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDownloader(t, Options{})
+	_, err := d.downloadURL(ctx, "invalid-url", &Metrics{})
+	if err == nil {
+		t.Errorf("Expected error for invalid URL, but got nil")
+	} else {
+		t.Logf("TestDownloadURL_InvalidURL passed")
+	}
+}
+
+// Test HTTP error response
+func TestDownloadURL_HTTPError(t *testing.T) {
+	server := mockHTTPServer("Not Found", http.StatusNotFound)
+	defer server.Close()
+
+	ctx := context.Background()
+	d := newTestDownloader(t, Options{})
+	_, err := d.downloadURL(ctx, server.URL, &Metrics{})
+
+	if err == nil {
+		t.Errorf("Expected HTTP error, but got nil")
+	} else {
+		t.Logf("TestDownloadURL_HTTPError passed")
+	}
+}
+
+// Test with context cancellation
+func TestDownloadURL_ContextCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select { // Delayed Response
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	d := newTestDownloader(t, Options{})
+	_, err := d.downloadURL(ctx, server.URL, &Metrics{})
+
+	if err == nil {
+		t.Errorf("Expected context deadline exceeded error, but got nil")
+	} else {
+		t.Logf("TestDownloadURL_ContextCancel passed")
+	}
+}
+
+// Test downloadURLs function (concurrent downloads)
+func TestDownloadURLs(t *testing.T) {
+	server := mockHTTPServer("mock data", http.StatusOK)
+	defer server.Close()
+
+	urlChan := make(chan job, 2)
+	contentChan := make(chan Download, 2)
+	metrics := &Metrics{}
+	ctx := context.Background()
+	wg := &sync.WaitGroup{}
+
+	// Send URLs to the channel
+	urlChan <- job{url: server.URL}
+	urlChan <- job{url: server.URL}
+	close(urlChan)
+
+	// Start downloading
+	d := newTestDownloader(t, Options{})
+	d.downloadURLs(ctx, urlChan, contentChan, metrics, wg)
+
+	wg.Wait()
+	close(contentChan)
+
+	count := 0
+	for range contentChan {
+		count++
+	}
+
+	if count != 2 || metrics.TotalURLs.Load() != 2 {
+		t.Errorf("Expected 2 downloads, got %d", count)
+	} else {
+		t.Logf("TestDownloadURLs passed")
+	}
+}
+
+// Test responses larger than MaxBodySize are skipped
+func TestDownloadURL_MaxSize(t *testing.T) {
+	server := mockHTTPServer("0123456789", http.StatusOK)
+	defer server.Close()
+
+	d := newTestDownloader(t, Options{MaxBodySize: 5})
+	_, err := d.downloadURL(context.Background(), server.URL, &Metrics{})
+	var skipErr *skipError
+	if !errors.As(err, &skipErr) {
+		t.Errorf("Expected skip error for oversized body, got %v", err)
+	}
+}
+
+// Test Content-Type allow and deny lists
+func TestCheckContentType(t *testing.T) {
+	d := newTestDownloader(t, Options{AllowTypes: []string{"text/*", "application/pdf"}, DenyTypes: []string{"text/csv"}})
+
+	tests := map[string]bool{
+		"text/html; charset=utf-8": true,
+		"application/pdf":          true,
+		"text/csv":                 false,
+		"video/mp4":                false,
+		"":                         false,
+	}
+	for contentType, allowed := range tests {
+		err := d.checkContentType(contentType)
+		if allowed && err != nil {
+			t.Errorf("Expected %q to be allowed, got %v", contentType, err)
+		}
+		if !allowed && err == nil {
+			t.Errorf("Expected %q to be rejected", contentType)
+		}
+	}
+}
+
+// Test two downloaders run side by side without sharing state
+func TestRun_Independent(t *testing.T) {
+	server := mockHTTPServer("mock data", http.StatusOK)
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	results := make([]Result, 2)
+	for i := range results {
+		d := newTestDownloader(t, Options{Workers: 2})
+		sink, err := NewDirSink(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		urls := SliceSource{server.URL, server.URL + "/a", server.URL + "/b"}[:i+2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = d.Run(context.Background(), urls, sink)
+		}()
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if result.Status != StatusSuccess || result.Successes != uint64(i+2) || result.Total != uint64(i+2) {
+			t.Errorf("Run %d: expected %d successes, got %+v", i, i+2, result)
+		}
+	}
+}
+
+// Test the error of the source is returned with the partial result
+func TestRun_SourceError(t *testing.T) {
+	d := newTestDownloader(t, Options{})
+	result, err := d.Run(context.Background(), CSVSource{Path: "non_existent_file.csv"}, &DirSink{Dir: t.TempDir()})
+	if err == nil {
+		t.Errorf("Expected error for missing input file, but got nil")
+	}
+	if result.Total != 0 {
+		t.Errorf("Expected no URL, got %d", result.Total)
+	}
+}
+
+// Test invalid rows are recorded in the rejects file and downloads in the manifest with their input rows
+func TestRun_Inputs(t *testing.T) {
+	server := mockHTTPServer("mock data", http.StatusOK)
+	defer server.Close()
+	filePath, err := createTempCSV("url\n" + server.URL + "/a#top\nnot a url\n\"x\",\"y\"\n")
+	if err != nil {
+		t.Fatalf("Failed to create CSV: %v", err)
+	}
+	defer os.Remove(filePath)
+
+	dir := t.TempDir()
+	rejects, err := OpenRejectLog(filepath.Join(dir, "rejects.csv"))
+	if err != nil {
+		t.Fatalf("Failed to open rejects file: %v", err)
+	}
+	manifest, err := OpenManifest(filepath.Join(dir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	d := newTestDownloader(t, Options{Rejects: rejects, Manifest: manifest})
+	result, err := d.Run(context.Background(), CSVSource{Path: filePath}, &DirSink{Dir: t.TempDir()})
+	rejects.Close()
+	manifest.Close()
+	if err != nil || result.Total != 1 || result.Successes != 1 {
+		t.Fatalf("Expected the single valid URL to be downloaded, got %+v (%v)", result, err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(dir, "rejects.csv"))
+	expected := "file,line,row,text,reason\n" +
+		filePath + ",3,2,not a url,\"invalid character \"\" \"\" in host name\"\n" +
+		filePath + ",4,3,\"x,y\",\"expected 1 column, got 2\"\n"
+	if string(content) != expected {
+		t.Errorf("Expected rejects:\n%s\ngot:\n%s", expected, content)
+	}
+
+	entries, err := ReadManifest(filepath.Join(dir, "manifest.jsonl"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected 1 manifest entry, got %v (%v)", entries, err)
+	}
+	expectedInput := Input{File: filePath, Line: 2, Row: 1, Text: server.URL + "/a#top"}
+	if entries[0].URL != server.URL+"/a" || entries[0].Input == nil || *entries[0].Input != expectedInput {
+		t.Errorf("Expected the normalized URL read from %+v, got %+v", expectedInput, entries[0])
+	}
+}
+
+// Test URLs read without a scheme fall back to http when https fails, with a retry event, and the scheme and fetched URL are recorded in the manifest
+func TestRun_HTTPFallback(t *testing.T) {
+	server := mockHTTPServer("mock data", http.StatusOK) // plain HTTP, the TLS handshake fails
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	manifestFile := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := OpenManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	hooks := &recordingHooks{}
+	d := newTestDownloader(t, Options{HTTPFallback: true, Manifest: manifest, Hooks: hooks})
+	result, _ := d.Run(context.Background(), SliceSource{host + "/bare", "https://" + host + "/explicit"}, &DirSink{Dir: t.TempDir()})
+	manifest.Close()
+	if result.Successes != 1 || result.Failures != 1 {
+		t.Fatalf("Expected only the URL without a scheme to fall back, got %+v", result)
+	}
+
+	entries, err := ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	schemes := make(map[string]string)
+	for _, entry := range entries {
+		schemes[entry.URL] = strings.TrimSpace(entry.Outcome + " " + entry.Scheme + " " + entry.FetchedURL)
+	}
+	if schemes["https://"+host+"/bare"] != "success http http://"+host+"/bare" || schemes["https://"+host+"/explicit"] != "failed https" {
+		t.Errorf("Expected the fallback scheme and URL in the manifest, got %v", schemes)
+	}
+	if !slices.Contains(hooks.events, "retry http://"+host+"/bare") || slices.Contains(hooks.events, "retry https://"+host+"/explicit") {
+		t.Errorf("Expected a retry event for the fallback only, got %v", hooks.events)
+	}
+}
+
+// Test a run over several inputs writes the manifest, rejects and downloads of every input apart, skips the duplicates of each input and counts them per input
+func TestRun_MultipleInputs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("mock data"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	inputs := []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.jsonl")}
+	os.WriteFile(inputs[0], []byte("url\n"+server.URL+"/a1\nnot a url\n"+server.URL+"/a2\n"+server.URL+"/a1#dup\n"), 0644)
+	os.WriteFile(inputs[1], []byte(`{"url": "`+server.URL+`/b1"}`+"\n"+`{"url": "`+server.URL+`/a1#top"}`+"\n"+`{"url": "`+server.URL+`/missing"}`+"\n"), 0644)
+
+	metrics := &Metrics{}
+	manifests := make(map[string]*Manifest)
+	rejects := make(map[string]*RejectLog)
+	sinks := make(map[string]Sink)
+	for _, input := range inputs {
+		var err error
+		if manifests[input], err = OpenManifest(input + ".manifest.jsonl"); err != nil {
+			t.Fatalf("Failed to open manifest: %v", err)
+		}
+		if rejects[input], err = OpenRejectLog(input + ".rejects.csv"); err != nil {
+			t.Fatalf("Failed to open rejects file: %v", err)
+		}
+		if sinks[input], err = NewDirSink(input + ".downloads"); err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+	}
+	d := newTestDownloader(t, Options{Metrics: metrics, InputManifests: manifests, InputRejects: rejects})
+	source := MultiSource{FileSource(inputs[0]), FileSource(inputs[1])}
+	result, err := d.Run(context.Background(), source, InputSinks{Sinks: sinks})
+	for _, input := range inputs {
+		manifests[input].Close()
+		rejects[input].Close()
+	}
+	if err != nil || result.Total != 5 || result.Successes != 4 || result.Failures != 1 {
+		t.Fatalf("Expected 4 successes and 1 failure over both inputs, got %+v (%v)", result, err)
+	}
+
+	for i, expected := range []int{2, 3} {
+		entries, err := ReadManifest(inputs[i] + ".manifest.jsonl")
+		if err != nil || len(entries) != expected {
+			t.Fatalf("Expected %d manifest entries for %s, got %v (%v)", expected, inputs[i], entries, err)
+		}
+		for _, entry := range entries {
+			if entry.Input == nil || entry.Input.File != inputs[i] {
+				t.Errorf("Expected the entries of %s only, got %+v", inputs[i], entry)
+			}
+		}
+	}
+	if files, _ := os.ReadDir(inputs[0] + ".downloads"); len(files) != 2 {
+		t.Errorf("Expected 2 files downloaded from %s, got %d", inputs[0], len(files))
+	}
+	if content, _ := os.ReadFile(inputs[1] + ".rejects.csv"); string(content) != "file,line,row,text,reason\n" {
+		t.Errorf("Expected no rejected row for %s, got:\n%s", inputs[1], content)
+	}
+
+	expected := []InputReport{
+		{File: inputs[0], URLs: 2, Rejected: 1, Duplicates: 1, Successes: 2, Bytes: 18},
+		{File: inputs[1], URLs: 3, Successes: 2, Failures: 1, Bytes: 18},
+	}
+	if reports := metrics.InputReports(); len(reports) != 2 || reports[0] != expected[0] || reports[1] != expected[1] {
+		t.Errorf("Expected the input stats %+v, got %+v", expected, reports)
+	}
+}
+
+// Test the rows giving a SHA-256 fail as checksum mismatches when the body doesn't match it
+func TestRun_ExpectedChecksum(t *testing.T) {
+	server := mockHTTPServer("mock data", http.StatusOK)
+	defer server.Close()
+	sum := sha256.Sum256([]byte("mock data"))
+	good := hex.EncodeToString(sum[:])
+	filePath, err := createTempCSV("url,sha256\n" + server.URL + "/good," + strings.ToUpper(good) + "\n" +
+		server.URL + "/bad," + strings.Repeat("0", 64) + "\n" + server.URL + "/unchecked,\n" + server.URL + "/invalid,abc\n")
+	if err != nil {
+		t.Fatalf("Failed to create CSV: %v", err)
+	}
+	defer os.Remove(filePath)
+
+	metrics := &Metrics{}
+	d := newTestDownloader(t, Options{Metrics: metrics})
+	result, err := d.Run(context.Background(), CSVSource{Path: filePath}, &DirSink{Dir: t.TempDir()})
+	if err != nil || result.Total != 3 || result.Successes != 2 || result.Failures != 1 {
+		t.Fatalf("Expected 2 successes and 1 checksum failure, got %+v (%v)", result, err)
+	}
+	if counts := metrics.FailureCounts(); counts[FailureChecksum] != 1 {
+		t.Errorf("Expected 1 %s failure, got %v", FailureChecksum, counts)
+	}
+	report := metrics.BuildReport(filePath)
+	if len(report.FailedURLs) != 1 || report.FailedURLs[0].URL != server.URL+"/bad" || report.FailedURLs[0].Input.SHA256 != strings.Repeat("0", 64) {
+		t.Errorf("Expected the bad URL with its expected checksum in the report, got %+v", report.FailedURLs)
+	}
+}
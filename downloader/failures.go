@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Failure classes recorded in Metrics and the manifest. HTTP status failures
+// are recorded per code as `http_<code>`, e.g. http_404 or http_503. A body
+// that doesn't match the SHA-256 given by its input row is a checksum mismatch,
+// as is a saved file that no longer matches the manifest (see VerifyManifest).
+const (
+	FailureDNS      = "dns"
+	FailureConnect  = "connect"
+	FailureTLS      = "tls"
+	FailureTimeout  = "timeout"
+	FailureBodyRead = "body_read"
+	FailureWrite    = "write"
+	FailureChecksum = "checksum_mismatch"
+	FailureCanceled = "cancelled"
+	FailureOther    = "other"
+)
+
+// httpStatusError reports a response with a status other than 200 OK.
+type httpStatusError struct {
+	code int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP error: %d", e.code)
+}
+
+// bodyReadError reports a failure while reading the response body.
+type bodyReadError struct {
+	err error
+}
+
+func (e *bodyReadError) Error() string {
+	return fmt.Sprintf("reading body: %v", e.err)
+}
+
+func (e *bodyReadError) Unwrap() error {
+	return e.err
+}
+
+// checksumError reports a body that doesn't match the SHA-256 of its input row.
+type checksumError struct {
+	expected, got string
+}
+
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("expected sha256 %s, got %s", e.expected, e.got)
+}
+
+// checkSHA256 returns a *checksumError if content doesn't match the expected
+// hex SHA-256, nil if it does or nothing is expected.
+func checkSHA256(content []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	sum := sha256.Sum256(content)
+	if got := hex.EncodeToString(sum[:]); got != expected {
+		return &checksumError{expected: expected, got: got}
+	}
+	return nil
+}
+
+// classifyFailure maps a download error to its failure class.
+//
+// Notes:
+// - Cancellation and timeouts are checked first, since they are usually wrapped in other errors.
+// - Errors that match no class are reported as FailureOther.
+func classifyFailure(err error) string {
+	var statusErr *httpStatusError
+	var bodyErr *bodyReadError
+	var sumErr *checksumError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return FailureCanceled
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("http_%d", statusErr.code)
+	case errors.As(err, &sumErr):
+		return FailureChecksum
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case errors.As(err, &dnsErr):
+		return FailureDNS
+	case isTLSError(err):
+		return FailureTLS
+	case errors.As(err, &bodyErr):
+		return FailureBodyRead
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return FailureConnect
+	}
+	return FailureOther
+}
+
+// isTLSError reports whether err comes from the TLS handshake or certificate
+// verification, including a plain HTTP server answering an https request.
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) ||
+		strings.Contains(err.Error(), "tls: ") || strings.Contains(err.Error(), "server gave HTTP response to HTTPS client")
+}
+
+// failureFamily groups a failure class for the summary table, e.g. http_404 into "HTTP 4xx".
+func failureFamily(class string) string {
+	if code, ok := strings.CutPrefix(class, "http_"); ok && len(code) == 3 {
+		return "HTTP " + code[:1] + "xx"
+	}
+	return class
+}
@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test HTTP status failures are classified per code
+func TestClassifyFailure_HTTPStatus(t *testing.T) {
+	d := newTestDownloader(t, Options{})
+	server := mockHTTPServer("Service Unavailable", http.StatusServiceUnavailable)
+	defer server.Close()
+
+	_, err := d.downloadURL(context.Background(), server.URL, &Metrics{})
+	if class := classifyFailure(err); class != "http_503" {
+		t.Errorf("Expected class http_503, got %s", class)
+	}
+	if family := failureFamily("http_503"); family != "HTTP 5xx" {
+		t.Errorf("Expected family HTTP 5xx, got %s", family)
+	}
+}
+
+// Test network level failures
+func TestClassifyFailure_Network(t *testing.T) {
+	d := newTestDownloader(t, Options{})
+	// Unresolvable host (.invalid is reserved)
+	_, err := d.downloadURL(context.Background(), "http://host.invalid/", &Metrics{})
+	if class := classifyFailure(err); class != FailureDNS && class != FailureTimeout {
+		t.Errorf("Expected class dns, got %s (%v)", class, err)
+	}
+
+	// Closed port
+	server := httptest.NewServer(http.NotFoundHandler())
+	addr := server.URL
+	server.Close()
+	_, err = d.downloadURL(context.Background(), addr, &Metrics{})
+	if class := classifyFailure(err); class != FailureConnect {
+		t.Errorf("Expected class connect, got %s (%v)", class, err)
+	}
+
+	// Untrusted certificate
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	_, err = d.downloadURL(context.Background(), tlsServer.URL, &Metrics{})
+	if class := classifyFailure(err); class != FailureTLS {
+		t.Errorf("Expected class tls, got %s (%v)", class, err)
+	}
+
+	// Plain HTTP server requested over https
+	plainServer := httptest.NewServer(http.NotFoundHandler())
+	defer plainServer.Close()
+	_, err = d.downloadURL(context.Background(), "https://"+plainServer.Listener.Addr().String(), &Metrics{})
+	if class := classifyFailure(err); class != FailureTLS {
+		t.Errorf("Expected class tls, got %s (%v)", class, err)
+	}
+}
+
+// Test timeouts and cancellation
+func TestClassifyFailure_Context(t *testing.T) {
+	d := newTestDownloader(t, Options{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := d.downloadURL(ctx, server.URL, &Metrics{})
+	if class := classifyFailure(err); class != FailureTimeout {
+		t.Errorf("Expected class timeout, got %s (%v)", class, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = d.downloadURL(ctx, server.URL, &Metrics{})
+	if class := classifyFailure(err); class != FailureCanceled {
+		t.Errorf("Expected class cancelled, got %s (%v)", class, err)
+	}
+}
+
+// Test failure counters per class
+func TestMetrics_FailureCounts(t *testing.T) {
+	m := &Metrics{}
+	m.AddFailure("http_404")
+	m.AddFailure("http_404")
+	m.AddFailure(FailureDNS)
+
+	counts := m.FailureCounts()
+	if counts["http_404"] != 2 || counts[FailureDNS] != 1 {
+		t.Errorf("Unexpected failure counts: %v", counts)
+	}
+	if m.FailureCount.Load() != 3 {
+		t.Errorf("Expected FailureCount=3, got %d", m.FailureCount.Load())
+	}
+}
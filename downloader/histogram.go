@@ -0,0 +1,115 @@
+package downloader
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+const (
+	HISTOGRAM_SUB_BUCKETS = 4                            // buckets per power of two, ~19% relative error
+	HISTOGRAM_BUCKETS     = 64*HISTOGRAM_SUB_BUCKETS + 1 // covers the whole uint64 range
+)
+
+// Histogram is a lock free log-linear histogram of non-negative values such as
+// durations in nanoseconds or sizes in bytes. Bucket 0 holds zero and bucket
+// i > 0 holds the values in (2^((i-2)/4), 2^((i-1)/4)].
+type Histogram struct {
+	buckets [HISTOGRAM_BUCKETS]atomic.Uint64
+	count   atomic.Uint64
+	sum     atomic.Uint64
+	max     atomic.Uint64
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(value uint64) {
+	h.buckets[bucketIndex(value)].Add(1)
+	h.count.Add(1)
+	h.sum.Add(value)
+	for {
+		current := h.max.Load()
+		if value <= current || h.max.CompareAndSwap(current, value) {
+			return
+		}
+	}
+}
+
+// Count returns the number of observed values.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// Sum returns the sum of the observed values.
+func (h *Histogram) Sum() uint64 {
+	return h.sum.Load()
+}
+
+// Max returns the largest observed value.
+func (h *Histogram) Max() uint64 {
+	return h.max.Load()
+}
+
+// Percentile returns the upper bound of the bucket holding the p-th percentile
+// (0 < p <= 100), capped at the largest observed value.
+func (h *Histogram) Percentile(p float64) uint64 {
+	count := h.count.Load()
+	if count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(count)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i := range h.buckets {
+		seen += h.buckets[i].Load()
+		if seen >= rank {
+			return min(bucketUpperBound(i), h.max.Load())
+		}
+	}
+	return h.max.Load()
+}
+
+// Buckets calls fn with the upper bound and cumulative count of every non-empty
+// bucket, in increasing order.
+func (h *Histogram) Buckets(fn func(upperBound uint64, cumulative uint64)) {
+	var seen uint64
+	for i := range h.buckets {
+		n := h.buckets[i].Load()
+		if n == 0 {
+			continue
+		}
+		seen += n
+		fn(bucketUpperBound(i), seen)
+	}
+}
+
+// CountAtOrBelow returns the number of observed values in the buckets whose
+// upper bound does not exceed value, i.e. an approximation of values <= value.
+func (h *Histogram) CountAtOrBelow(value uint64) uint64 {
+	var seen uint64
+	for i := 0; i < HISTOGRAM_BUCKETS && bucketUpperBound(i) <= value; i++ {
+		seen += h.buckets[i].Load()
+	}
+	return seen
+}
+
+// bucketIndex returns the bucket for value.
+func bucketIndex(value uint64) int {
+	if value == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(math.Log2(float64(value)) * HISTOGRAM_SUB_BUCKETS))
+	return min(max(idx, 0), HISTOGRAM_BUCKETS-2) + 1
+}
+
+// bucketUpperBound returns the largest value held by bucket i.
+func bucketUpperBound(i int) uint64 {
+	if i == 0 {
+		return 0
+	}
+	bound := math.Pow(2, float64(i-1)/HISTOGRAM_SUB_BUCKETS)
+	if bound >= math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return uint64(bound)
+}
@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"sync"
+	"testing"
+)
+
+// Test percentiles stay within the bucket error and are capped at the max
+func TestHistogram_Percentile(t *testing.T) {
+	h := &Histogram{}
+	for i := uint64(1); i <= 1000; i++ {
+		h.Observe(i)
+	}
+
+	if h.Count() != 1000 {
+		t.Errorf("Expected count 1000, got %d", h.Count())
+	}
+	if h.Max() != 1000 {
+		t.Errorf("Expected max 1000, got %d", h.Max())
+	}
+	for p, expected := range map[float64]uint64{50: 500, 90: 900, 99: 990} {
+		got := h.Percentile(p)
+		if got < expected || float64(got) > float64(expected)*1.2 {
+			t.Errorf("Percentile(%v) = %d, expected within 20%% above %d", p, got, expected)
+		}
+	}
+	if got := h.Percentile(100); got != 1000 {
+		t.Errorf("Expected p100 to equal the max 1000, got %d", got)
+	}
+}
+
+// Test an empty histogram and concurrent observations
+func TestHistogram_Concurrent(t *testing.T) {
+	h := &Histogram{}
+	if h.Percentile(50) != 0 {
+		t.Errorf("Expected 0 for an empty histogram")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := uint64(0); j < 100; j++ {
+				h.Observe(j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if h.Count() != 1000 {
+		t.Errorf("Expected count 1000, got %d", h.Count())
+	}
+	if h.Sum() != 10*4950 {
+		t.Errorf("Expected sum %d, got %d", 10*4950, h.Sum())
+	}
+}
@@ -0,0 +1,28 @@
+package downloader
+
+// Hooks receives the lifecycle events of every URL of a run. Embed NoHooks to
+// implement only some of them.
+//
+// Notes:
+// - OnQueued and OnRetry are called from Stage 1, OnStart, OnSuccess and OnFailure from the download workers, OnPersisted from Stage 3.
+// - The download workers also call OnRetry before requesting a URL again over http (see Options.HTTPFallback).
+// - Download workers run concurrently, the methods must be safe for concurrent use.
+// - The stages wait for the methods to return, slow hooks slow the run down.
+type Hooks interface {
+	OnQueued(url string)                        // the URL was read from the source
+	OnRetry(url string)                         // the URL failed in a previous run and is queued again (see RetrySource), or is requested again over http (see Options.HTTPFallback)
+	OnStart(url string)                         // the request is about to be sent
+	OnSuccess(download Download)                // the body was downloaded
+	OnFailure(url string, err error)            // the download or the sink failed, see classifyFailure
+	OnPersisted(download Download, path string) // the sink stored the body at path
+}
+
+// NoHooks ignores every event.
+type NoHooks struct{}
+
+func (NoHooks) OnQueued(url string)                        {}
+func (NoHooks) OnRetry(url string)                         {}
+func (NoHooks) OnStart(url string)                         {}
+func (NoHooks) OnSuccess(download Download)                {}
+func (NoHooks) OnFailure(url string, err error)            {}
+func (NoHooks) OnPersisted(download Download, path string) {}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingHooks records every event as "event url"
+type recordingHooks struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *recordingHooks) add(event string, url string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event+" "+url)
+}
+
+func (h *recordingHooks) OnQueued(url string)             { h.add("queued", url) }
+func (h *recordingHooks) OnRetry(url string)              { h.add("retry", url) }
+func (h *recordingHooks) OnStart(url string)              { h.add("start", url) }
+func (h *recordingHooks) OnSuccess(download Download)     { h.add("success", download.URL) }
+func (h *recordingHooks) OnFailure(url string, err error) { h.add("failure", url) }
+func (h *recordingHooks) OnPersisted(download Download, path string) {
+	h.add("persisted", download.URL)
+}
+
+// Test every lifecycle event is reported once per URL
+func TestRun_Hooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	hooks := &recordingHooks{}
+	d := newTestDownloader(t, Options{Hooks: hooks})
+	source := RetrySource{{URL: server.URL + "/ok"}, {URL: server.URL + "/missing"}}
+	if _, err := d.Run(context.Background(), source, &DirSink{Dir: t.TempDir()}); err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+
+	var expected []string
+	for _, event := range []string{"retry /ok", "queued /ok", "start /ok", "success /ok", "persisted /ok",
+		"retry /missing", "queued /missing", "start /missing", "failure /missing"} {
+		expected = append(expected, strings.Replace(event, " ", " "+server.URL, 1))
+	}
+	sort.Strings(expected)
+	sort.Strings(hooks.events)
+	if strings.Join(hooks.events, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected events %v, got %v", expected, hooks.events)
+	}
+}
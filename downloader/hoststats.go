@@ -0,0 +1,168 @@
+package downloader
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const TOP_HOSTS = 10 // hosts listed in the summary, the JSON report has all of them
+
+// HostStats tracks the downloads of a single host.
+type HostStats struct {
+	Requests  atomic.Uint64 // Number of requests sent to the host
+	Successes atomic.Uint64 // Number of successful downloads
+	Failures  atomic.Uint64 // Number of failed downloads
+	Skipped   atomic.Uint64 // Number of responses rejected by the size/Content-Type limits
+	Blocked   atomic.Uint64 // Number of URLs disallowed by robots.txt, never requested
+	Bytes     atomic.Uint64 // Total bytes of successful downloads
+	Latency   Histogram     // Download duration of successful downloads (in nanoseconds)
+
+	failuresMu sync.Mutex
+	failures   map[string]uint64 // Number of failures per failure class
+}
+
+// HostReport is the JSON representation of HostStats.
+type HostReport struct {
+	Host      string            `json:"host"`
+	Requests  uint64            `json:"requests"`
+	Successes uint64            `json:"successes"`
+	Failures  uint64            `json:"failures"`
+	Skipped   uint64            `json:"skipped"`
+	Blocked   uint64            `json:"blocked"`
+	Bytes     uint64            `json:"bytes"`
+	Classes   map[string]uint64 `json:"failure_classes,omitempty"`
+	LatencyMs struct {
+		P50   float64 `json:"p50"`
+		P90   float64 `json:"p90"`
+		P99   float64 `json:"p99"`
+		Max   float64 `json:"max"`
+		Total float64 `json:"total"`
+	} `json:"latency_ms"`
+}
+
+// AddSuccess records a successful download of the host.
+func (h *HostStats) AddSuccess(duration time.Duration, bytes int) {
+	h.Successes.Add(1)
+	h.Bytes.Add(uint64(bytes))
+	h.Latency.Observe(uint64(duration.Nanoseconds()))
+}
+
+// AddFailure records a failed download of the host.
+func (h *HostStats) AddFailure(class string) {
+	h.Failures.Add(1)
+	h.failuresMu.Lock()
+	defer h.failuresMu.Unlock()
+	if h.failures == nil {
+		h.failures = make(map[string]uint64)
+	}
+	h.failures[class]++
+}
+
+// Host returns the statistics of host, creating them on first use.
+func (m *Metrics) Host(host string) *HostStats {
+	m.hostsMu.Lock()
+	defer m.hostsMu.Unlock()
+	if m.hosts == nil {
+		m.hosts = make(map[string]*HostStats)
+	}
+	stats, ok := m.hosts[host]
+	if !ok {
+		stats = &HostStats{}
+		m.hosts[host] = stats
+	}
+	return stats
+}
+
+// HostReports returns a snapshot of every host, the hosts with the largest
+// total download time first.
+func (m *Metrics) HostReports() []HostReport {
+	m.hostsMu.Lock()
+	defer m.hostsMu.Unlock()
+
+	reports := make([]HostReport, 0, len(m.hosts))
+	for host, stats := range m.hosts {
+		report := HostReport{
+			Host:      host,
+			Requests:  stats.Requests.Load(),
+			Successes: stats.Successes.Load(),
+			Failures:  stats.Failures.Load(),
+			Skipped:   stats.Skipped.Load(),
+			Blocked:   stats.Blocked.Load(),
+			Bytes:     stats.Bytes.Load(),
+		}
+		stats.failuresMu.Lock()
+		if len(stats.failures) > 0 {
+			report.Classes = make(map[string]uint64, len(stats.failures))
+			for class, count := range stats.failures {
+				report.Classes[class] = count
+			}
+		}
+		stats.failuresMu.Unlock()
+		report.LatencyMs.P50 = millis(stats.Latency.Percentile(50))
+		report.LatencyMs.P90 = millis(stats.Latency.Percentile(90))
+		report.LatencyMs.P99 = millis(stats.Latency.Percentile(99))
+		report.LatencyMs.Max = millis(stats.Latency.Max())
+		report.LatencyMs.Total = millis(stats.Latency.Sum())
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].LatencyMs.Total != reports[j].LatencyMs.Total {
+			return reports[i].LatencyMs.Total > reports[j].LatencyMs.Total
+		}
+		return reports[i].Host < reports[j].Host
+	})
+	return reports
+}
+
+// logTopHosts logs the TOP_HOSTS hosts with the largest total download time.
+func (m *Metrics) logTopHosts(zlog zerolog.Logger) {
+	reports := m.HostReports()
+	if len(reports) == 0 {
+		return
+	}
+	log.Printf("Top hosts by download time:")
+	log.Printf("  %-30s %8s %8s %8s %12s %10s %10s", "Host", "Requests", "Success", "Failures", "Bytes", "p50", "p99")
+	for i, report := range reports {
+		if i == TOP_HOSTS {
+			break
+		}
+		log.Printf("  %-30s %8d %8d %8d %12d %10v %10v", report.Host, report.Requests, report.Successes, report.Failures, report.Bytes,
+			time.Duration(report.LatencyMs.P50*float64(time.Millisecond)).Round(time.Millisecond),
+			time.Duration(report.LatencyMs.P99*float64(time.Millisecond)).Round(time.Millisecond))
+		zlog.Info().Str("Host", report.Host).Uint64("Requests", report.Requests).Uint64("Success", report.Successes).
+			Uint64("Failures", report.Failures).Uint64("Bytes", report.Bytes).Float64("p50 ms", report.LatencyMs.P50).
+			Float64("p99 ms", report.LatencyMs.P99).Msg("Top host")
+	}
+}
+
+// WriteHostReport writes the statistics of every host as a JSON array.
+func (m *Metrics) WriteHostReport(filePath string) error {
+	data, err := json.MarshalIndent(m.HostReports(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// hostOf returns the lower cased host name of a URL, or the raw value if it cannot be parsed.
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return rawURL
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// millis converts nanoseconds to fractional milliseconds.
+func millis(nanos uint64) float64 {
+	return float64(nanos) / float64(time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test per host counters and the JSON report
+func TestMetrics_HostReports(t *testing.T) {
+	m := &Metrics{}
+	slow := m.Host(hostOf("https://Slow.example.com/a"))
+	slow.Requests.Add(2)
+	slow.AddSuccess(2*time.Second, 100)
+	slow.AddFailure("http_500")
+	fast := m.Host(hostOf("https://fast.example.com/b"))
+	fast.Requests.Add(1)
+	fast.AddSuccess(10*time.Millisecond, 50)
+
+	reports := m.HostReports()
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", len(reports))
+	}
+	if reports[0].Host != "slow.example.com" {
+		t.Errorf("Expected slow.example.com first, got %s", reports[0].Host)
+	}
+	if reports[0].Requests != 2 || reports[0].Successes != 1 || reports[0].Failures != 1 || reports[0].Classes["http_500"] != 1 {
+		t.Errorf("Unexpected report for slow host: %+v", reports[0])
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "hosts.json")
+	if err := m.WriteHostReport(reportPath); err != nil {
+		t.Fatalf("Failed to write host report: %v", err)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to read host report: %v", err)
+	}
+	var decoded []HostReport
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded) != 2 {
+		t.Errorf("Expected 2 hosts in the JSON report, got %d (%v)", len(decoded), err)
+	}
+}
+
+// Test the URLs blocked by robots.txt and skipped by the limits are counted per host
+func TestRun_HostBlockedSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /blocked\n"))
+		case "/large":
+			w.Write(make([]byte, 100))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	metrics := &Metrics{}
+	d := newTestDownloader(t, Options{Metrics: metrics, RespectRobots: true, MaxBodySize: 10})
+	d.Run(context.Background(), SliceSource{server.URL + "/blocked", server.URL + "/large", server.URL + "/ok"}, &DirSink{Dir: t.TempDir()})
+
+	reports := metrics.HostReports()
+	if len(reports) != 1 || reports[0].Successes != 1 || reports[0].Skipped != 1 || reports[0].Blocked != 1 {
+		t.Errorf("Expected 1 success, 1 skipped and 1 blocked URL for the host, got %+v", reports)
+	}
+}
@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// InputStats tracks the URLs of a single input file.
+type InputStats struct {
+	URLs       atomic.Uint64 // Number of URLs queued for download
+	Rejected   atomic.Uint64 // Number of rows rejected by Stage 1
+	Duplicates atomic.Uint64 // Number of URLs skipped by Stage 1 as already read
+	Successes  atomic.Uint64 // Number of successful downloads
+	Failures   atomic.Uint64 // Number of failed downloads
+	Skipped    atomic.Uint64 // Number of responses rejected by the size/Content-Type limits
+	Blocked    atomic.Uint64 // Number of URLs disallowed by robots.txt
+	Bytes      atomic.Uint64 // Total bytes of successful downloads
+}
+
+// InputReport is the JSON representation of InputStats.
+type InputReport struct {
+	File       string `json:"file"`
+	URLs       uint64 `json:"urls"`
+	Rejected   uint64 `json:"rejected"`
+	Duplicates uint64 `json:"duplicates"`
+	Successes  uint64 `json:"successes"`
+	Failures   uint64 `json:"failures"`
+	Skipped    uint64 `json:"skipped"`
+	Blocked    uint64 `json:"blocked"`
+	Bytes      uint64 `json:"bytes"`
+}
+
+// Input returns the statistics of the input file, "" for URLs that don't come
+// from a file, creating them on first use.
+func (m *Metrics) Input(file string) *InputStats {
+	m.inputsMu.Lock()
+	defer m.inputsMu.Unlock()
+	if m.inputs == nil {
+		m.inputs = make(map[string]*InputStats)
+	}
+	stats, ok := m.inputs[file]
+	if !ok {
+		stats = &InputStats{}
+		m.inputs[file] = stats
+		m.inputOrder = append(m.inputOrder, file)
+	}
+	return stats
+}
+
+// InputReports returns a snapshot of every input, in the order they were read.
+func (m *Metrics) InputReports() []InputReport {
+	m.inputsMu.Lock()
+	defer m.inputsMu.Unlock()
+
+	reports := make([]InputReport, 0, len(m.inputOrder))
+	for _, file := range m.inputOrder {
+		stats := m.inputs[file]
+		reports = append(reports, InputReport{
+			File:       file,
+			URLs:       stats.URLs.Load(),
+			Rejected:   stats.Rejected.Load(),
+			Duplicates: stats.Duplicates.Load(),
+			Successes:  stats.Successes.Load(),
+			Failures:   stats.Failures.Load(),
+			Skipped:    stats.Skipped.Load(),
+			Blocked:    stats.Blocked.Load(),
+			Bytes:      stats.Bytes.Load(),
+		})
+	}
+	return reports
+}
+
+// logInputs logs the counts of every input of a run reading several inputs.
+func (m *Metrics) logInputs(zlog zerolog.Logger) {
+	reports := m.InputReports()
+	if len(reports) < 2 {
+		return
+	}
+	log.Printf("Inputs:")
+	log.Printf("  %-40s %8s %8s %10s %8s %8s %8s %8s %12s", "File", "URLs", "Rejected", "Duplicates", "Success", "Failures", "Skipped", "Blocked", "Bytes")
+	for _, report := range reports {
+		log.Printf("  %-40s %8d %8d %10d %8d %8d %8d %8d %12d", report.File, report.URLs, report.Rejected, report.Duplicates, report.Successes, report.Failures, report.Skipped, report.Blocked, report.Bytes)
+		zlog.Info().Str("File", report.File).Uint64("URLs", report.URLs).Uint64("Rejected", report.Rejected).Uint64("Duplicates", report.Duplicates).
+			Uint64("Success", report.Successes).Uint64("Failures", report.Failures).Uint64("Skipped", report.Skipped).
+			Uint64("Blocked", report.Blocked).Uint64("Bytes", report.Bytes).Msg("Input")
+	}
+}
@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded in the manifest for every URL that reaches Stage 2.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailed  = "failed"
+	OutcomeSkipped = "skipped"
+	OutcomeBlocked = "blocked" // disallowed by robots.txt
+)
+
+// ManifestEntry describes the outcome of a single URL.
+type ManifestEntry struct {
+	URL          string    `json:"url"`
+	Input        *Input    `json:"input,omitempty"` // row the URL was read from
+	Outcome      string    `json:"outcome"`
+	Scheme       string    `json:"scheme,omitempty"`      // scheme of the request, http after an HTTP fallback
+	FetchedURL   string    `json:"fetched_url,omitempty"` // URL requested when it differs from URL, see Download.FetchedURL
+	Path         string    `json:"path,omitempty"`
+	Bytes        int64     `json:"bytes,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+	SHA256       string    `json:"sha256,omitempty"` // hex digest of the saved body, checked by VerifyManifest
+	Redirects    []string  `json:"redirects,omitempty"`
+	FailureClass string    `json:"failure_class,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// Manifest appends one JSON line per processed URL to a file, by default
+// `<filePath_without_extension>/manifest.jsonl` for the command line.
+// It is safe for concurrent use by the download workers and the persister.
+type Manifest struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// OpenManifest opens (or creates) the manifest file in append mode.
+func OpenManifest(path string) (*Manifest, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Record writes an entry to the manifest. A nil manifest is a no-op so that
+// stages can be exercised in isolation.
+func (m *Manifest) Record(entry ManifestEntry) error {
+	if m == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enc.Encode(entry)
+}
+
+// Close closes the underlying manifest file.
+func (m *Manifest) Close() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.file.Close()
+}
+
+// ReadManifest reads every entry of a manifest, oldest first.
+func ReadManifest(filePath string) ([]ManifestEntry, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []ManifestEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid manifest entry: %v", filePath, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// LatestEntries keeps the latest entry of every URL, in the order the URLs
+// were first recorded. A manifest appended to by several runs (e.g. --retry)
+// records a URL once per attempt.
+func LatestEntries(entries []ManifestEntry) []ManifestEntry {
+	index := make(map[string]int)
+	var latest []ManifestEntry
+	for _, entry := range entries {
+		if i, ok := index[entry.URL]; ok {
+			latest[i] = entry
+			continue
+		}
+		index[entry.URL] = len(latest)
+		latest = append(latest, entry)
+	}
+	return latest
+}
+
+// ManifestSummary summarizes the latest outcome of every URL of a manifest.
+type ManifestSummary struct {
+	Entries        int            `json:"entries"` // one per attempt
+	URLs           int            `json:"urls"`
+	Outcomes       map[string]int `json:"outcomes"`
+	FailureClasses map[string]int `json:"failure_classes"`
+	Bytes          int64          `json:"bytes"`
+	Hosts          int            `json:"hosts"`
+	FirstTime      time.Time      `json:"first_time"`
+	LastTime       time.Time      `json:"last_time"`
+}
+
+// SummarizeManifest counts the latest outcome of every URL of entries.
+func SummarizeManifest(entries []ManifestEntry) ManifestSummary {
+	summary := ManifestSummary{
+		Entries:        len(entries),
+		Outcomes:       make(map[string]int),
+		FailureClasses: make(map[string]int),
+	}
+	for _, entry := range entries {
+		if summary.FirstTime.IsZero() || entry.Time.Before(summary.FirstTime) {
+			summary.FirstTime = entry.Time
+		}
+		if entry.Time.After(summary.LastTime) {
+			summary.LastTime = entry.Time
+		}
+	}
+
+	hosts := make(map[string]bool)
+	for _, entry := range LatestEntries(entries) {
+		summary.URLs++
+		summary.Outcomes[entry.Outcome]++
+		if entry.Outcome == OutcomeFailed {
+			summary.FailureClasses[entry.FailureClass]++
+		}
+		summary.Bytes += entry.Bytes
+		hosts[hostOf(ensureScheme(entry.URL))] = true
+	}
+	summary.Hosts = len(hosts)
+	return summary
+}
@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes the manifest lines to a temporary file and returns its path
+func writeManifest(t *testing.T, lines ...string) string {
+	t.Helper()
+	manifestFile := filepath.Join(t.TempDir(), "manifest.jsonl")
+	if err := os.WriteFile(manifestFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	return manifestFile
+}
+
+// Test the summary counts the latest outcome of every URL
+func TestSummarizeManifest(t *testing.T) {
+	entries, err := ReadManifest(writeManifest(t,
+		`{"url":"www.a.com/1","outcome":"failed","failure_class":"timeout"}`,
+		`{"url":"www.a.com/2","outcome":"success","bytes":10}`,
+		`{"url":"www.b.com","outcome":"failed","failure_class":"http_404"}`,
+		`{"url":"www.a.com/1","outcome":"success","bytes":5}`,
+	))
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+
+	summary := SummarizeManifest(entries)
+	if summary.Entries != 4 || summary.URLs != 3 || summary.Hosts != 2 || summary.Bytes != 15 {
+		t.Errorf("Expected 4 entries, 3 URLs, 2 hosts and 15 bytes, got %+v", summary)
+	}
+	if summary.Outcomes[OutcomeSuccess] != 2 || summary.Outcomes[OutcomeFailed] != 1 {
+		t.Errorf("Expected 2 successes and 1 failure, got %v", summary.Outcomes)
+	}
+	if len(summary.FailureClasses) != 1 || summary.FailureClasses["http_404"] != 1 {
+		t.Errorf("Expected only the http_404 failure class, got %v", summary.FailureClasses)
+	}
+}
+
+// Test an invalid line is reported with its line number
+func TestReadManifest_Invalid(t *testing.T) {
+	manifestFile := writeManifest(t, `{"url":"www.a.com","outcome":"success"}`, `not json`)
+	if _, err := ReadManifest(manifestFile); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package downloader
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Metrics tracks the progress of URL processing
+type Metrics struct {
+	TotalURLs     atomic.Uint64 // Total number of URLs processed
+	ExpectedURLs  atomic.Uint64 // Number of data rows counted before Stage 1, used for the ETA
+	SuccessCount  atomic.Uint64 // Number of successful downloads
+	FailureCount  atomic.Uint64 // Number of failed downloads
+	SkippedCount  atomic.Uint64 // Number of responses rejected by the size/Content-Type limits
+	BlockedCount  atomic.Uint64 // Number of URLs disallowed by robots.txt
+	InFlight      atomic.Int64  // Number of downloads in progress
+	TotalDuration atomic.Uint64 // Total duration of all successful downloads (in nanoseconds)
+	TotalBytes    atomic.Uint64 // Total bytes of all successful downloads
+	Latency       Histogram     // Download duration of successful downloads (in nanoseconds)
+	TTFB          Histogram     // Time to first byte of successful downloads (in nanoseconds)
+	Size          Histogram     // Body size of successful downloads (in bytes)
+	PrcStartTime  time.Time     // Start of the run, set by Run unless already set
+	PrcEndTime    time.Time     // End of the run, read it once Run has returned
+
+	timesMu    sync.Mutex // guards PrcStartTime and PrcEndTime while the run is in progress
+	queuesMu   sync.Mutex
+	queues     func() map[string]int // depth of every pipeline channel, see QueueDepths
+	transfers  transferSet           // bodies being read, see Transfers
+	failuresMu sync.Mutex
+	failures   map[string]uint64 // Number of failures per failure class
+	failedURLs []FailedURL       // Failed URLs for the run report
+
+	hostsMu sync.Mutex
+	hosts   map[string]*HostStats // Statistics per host, see Host
+
+	inputsMu   sync.Mutex
+	inputs     map[string]*InputStats // Statistics per input file, see Input
+	inputOrder []string               // Input files in the order they were read
+}
+
+// start sets PrcStartTime unless the caller already did, e.g. to show progress from its own start.
+func (m *Metrics) start() {
+	m.timesMu.Lock()
+	defer m.timesMu.Unlock()
+	if m.PrcStartTime.IsZero() {
+		m.PrcStartTime = time.Now()
+	}
+	m.PrcEndTime = time.Time{}
+}
+
+// finish sets PrcEndTime once every stage has stopped.
+func (m *Metrics) finish() {
+	m.timesMu.Lock()
+	defer m.timesMu.Unlock()
+	m.PrcEndTime = time.Now()
+}
+
+// setQueues registers the function reporting the channel depths of the running pipeline.
+func (m *Metrics) setQueues(queues func() map[string]int) {
+	m.queuesMu.Lock()
+	defer m.queuesMu.Unlock()
+	m.queues = queues
+}
+
+// QueueDepths returns the number of items waiting in every pipeline channel by name.
+// It is empty before Run starts.
+func (m *Metrics) QueueDepths() map[string]int {
+	m.queuesMu.Lock()
+	queues := m.queues
+	m.queuesMu.Unlock()
+	if queues == nil {
+		return map[string]int{}
+	}
+	return queues()
+}
+
+// AddSuccess records a successful download with its total duration, time to first byte and size.
+func (m *Metrics) AddSuccess(duration time.Duration, ttfb time.Duration, bytes int) {
+	m.SuccessCount.Add(1)
+	m.TotalDuration.Add(uint64(duration.Nanoseconds()))
+	m.TotalBytes.Add(uint64(bytes))
+	m.Latency.Observe(uint64(duration.Nanoseconds()))
+	m.TTFB.Observe(uint64(ttfb.Nanoseconds()))
+	m.Size.Observe(uint64(bytes))
+}
+
+// AddFailure records a failed download of the given failure class.
+func (m *Metrics) AddFailure(class string) {
+	m.FailureCount.Add(1)
+	m.failuresMu.Lock()
+	defer m.failuresMu.Unlock()
+	if m.failures == nil {
+		m.failures = make(map[string]uint64)
+	}
+	m.failures[class]++
+}
+
+// FailureCounts returns a copy of the failure counters per class.
+func (m *Metrics) FailureCounts() map[string]uint64 {
+	m.failuresMu.Lock()
+	defer m.failuresMu.Unlock()
+	counts := make(map[string]uint64, len(m.failures))
+	for class, count := range m.failures {
+		counts[class] = count
+	}
+	return counts
+}
+
+func (m *Metrics) AddSkipped() {
+	m.SkippedCount.Add(1)
+}
+
+func (m *Metrics) AddBlocked() {
+	m.BlockedCount.Add(1)
+}
+
+// LogSummary logs the totals, the latency/TTFB/size percentiles and the throughput
+// of the run to the standard logger and to zlog.
+func (m *Metrics) LogSummary(zlog zerolog.Logger) {
+	totalURLs := m.TotalURLs.Load()
+	successCount := m.SuccessCount.Load()
+	failureCount := m.FailureCount.Load()
+	skippedCount := m.SkippedCount.Load()
+	blockedCount := m.BlockedCount.Load()
+	totalDuration := time.Duration(m.TotalDuration.Load())
+	avgDuration := time.Duration(0)
+	if successCount > 0 {
+		avgDuration = totalDuration / time.Duration(successCount)
+	}
+	elapsed := m.PrcEndTime.Sub(m.PrcStartTime)
+	mbPerSec, urlsPerSec := m.Throughput()
+
+	log.Printf("Summary: Total URLs=%d, Success=%d, Failures=%d, Skipped=%d, Blocked=%d, Avg Download Duration=%v", totalURLs, successCount, failureCount, skippedCount, blockedCount, avgDuration)
+	log.Printf("Latency: p50=%v, p90=%v, p99=%v, max=%v", durationPercentile(&m.Latency, 50), durationPercentile(&m.Latency, 90), durationPercentile(&m.Latency, 99), time.Duration(m.Latency.Max()))
+	log.Printf("TTFB: p50=%v, p90=%v, p99=%v, max=%v", durationPercentile(&m.TTFB, 50), durationPercentile(&m.TTFB, 90), durationPercentile(&m.TTFB, 99), time.Duration(m.TTFB.Max()))
+	log.Printf("Size: p50=%d, p90=%d, p99=%d, max=%d bytes", m.Size.Percentile(50), m.Size.Percentile(90), m.Size.Percentile(99), m.Size.Max())
+	log.Printf("Throughput: %.2f MB/s, %.2f URLs/s over %v", mbPerSec, urlsPerSec, elapsed)
+
+	zlog.Info().Uint64("Total URLs", totalURLs).Uint64("Success", successCount).Uint64("Failures", failureCount).Uint64("Skipped", skippedCount).Uint64("Blocked", blockedCount).Str("Avg Download Duration", avgDuration.String()).Str("Latency", elapsed.String()).Msg("Summary")
+	zlog.Info().
+		Str("Latency p50", durationPercentile(&m.Latency, 50).String()).Str("Latency p90", durationPercentile(&m.Latency, 90).String()).
+		Str("Latency p99", durationPercentile(&m.Latency, 99).String()).Str("Latency max", time.Duration(m.Latency.Max()).String()).
+		Str("TTFB p50", durationPercentile(&m.TTFB, 50).String()).Str("TTFB p90", durationPercentile(&m.TTFB, 90).String()).
+		Str("TTFB p99", durationPercentile(&m.TTFB, 99).String()).Str("TTFB max", time.Duration(m.TTFB.Max()).String()).
+		Uint64("Size p50", m.Size.Percentile(50)).Uint64("Size p90", m.Size.Percentile(90)).
+		Uint64("Size p99", m.Size.Percentile(99)).Uint64("Size max", m.Size.Max()).
+		Uint64("Total Bytes", m.TotalBytes.Load()).Float64("MB/s", mbPerSec).Float64("URLs/s", urlsPerSec).
+		Msg("Distribution")
+
+	m.logFailureBreakdown(zlog)
+	m.logTopHosts(zlog)
+	m.logInputs(zlog)
+}
+
+// logFailureBreakdown logs a table of failures per class, grouped by family
+// (HTTP 4xx, HTTP 5xx, dns, ...) with the largest families first.
+func (m *Metrics) logFailureBreakdown(zlog zerolog.Logger) {
+	counts := m.FailureCounts()
+	if len(counts) == 0 {
+		return
+	}
+	families := make(map[string]uint64)
+	classes := make([]string, 0, len(counts))
+	for class, count := range counts {
+		families[failureFamily(class)] += count
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		fi, fj := failureFamily(classes[i]), failureFamily(classes[j])
+		if fi != fj {
+			if families[fi] != families[fj] {
+				return families[fi] > families[fj]
+			}
+			return fi < fj
+		}
+		return classes[i] < classes[j]
+	})
+
+	log.Printf("Failures by class:")
+	event := zlog.Info()
+	for _, class := range classes {
+		log.Printf("  %-10s %-18s %d", failureFamily(class), class, counts[class])
+		event = event.Uint64(class, counts[class])
+	}
+	event.Msg("Failure breakdown")
+}
+
+// Throughput returns the downloaded MB/s and the completed URLs/s between
+// PrcStartTime and PrcEndTime (or now while the run is in progress).
+func (m *Metrics) Throughput() (mbPerSec float64, urlsPerSec float64) {
+	m.timesMu.Lock()
+	start, end := m.PrcStartTime, m.PrcEndTime
+	m.timesMu.Unlock()
+	if end.IsZero() {
+		end = time.Now()
+	}
+	seconds := end.Sub(start).Seconds()
+	if seconds <= 0 {
+		return 0, 0
+	}
+	completed := m.SuccessCount.Load() + m.FailureCount.Load() + m.SkippedCount.Load() + m.BlockedCount.Load()
+	return float64(m.TotalBytes.Load()) / (1024 * 1024) / seconds, float64(completed) / seconds
+}
+
+// durationPercentile returns the p-th percentile of a nanosecond histogram as a duration.
+func durationPercentile(h *Histogram, p float64) time.Duration {
+	return time.Duration(h.Percentile(p))
+}
@@ -0,0 +1,137 @@
+package downloader
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// trackingParams are the query parameters removed with URLOptions.StripTracking,
+// in addition to every utm_* parameter.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "gbraid": true, "wbraid": true, "msclkid": true,
+	"yclid": true, "mc_cid": true, "mc_eid": true, "igshid": true, "_ga": true, "_gl": true,
+}
+
+// NormalizeURL validates a URL read from a source and returns its normalized form.
+//
+// Input:
+// - rawURL: URL as read, without a scheme it defaults to opts.DefaultScheme (https).
+// - opts: default scheme, optional tracking parameter removal and private address rejection.
+//
+// Output:
+// - Returns the URL with a lower cased, IDNA encoded (punycode) host and without fragment.
+// - Returns an error describing why the URL is rejected: unparsable, not http(s), without host or, with RejectPrivate, a private address.
+//
+// Notes:
+// - The order and encoding of the remaining query parameters are kept.
+// - RejectPrivate only checks literal addresses and localhost, names resolving to private addresses are not looked up.
+// - Redirects to such addresses are refused by the client of the Downloader, see rejectPrivateRedirects.
+func NormalizeURL(rawURL string, opts URLOptions) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("empty URL")
+	}
+	if !hasScheme(rawURL) {
+		rawURL = opts.defaultScheme() + "://" + rawURL
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err.(*url.Error).Err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+	if target.Hostname() == "" {
+		return "", fmt.Errorf("missing host")
+	}
+
+	host := strings.ToLower(target.Hostname())
+	ip := net.ParseIP(host)
+	if ip == nil {
+		if host, err = idna.Lookup.ToASCII(host); err != nil {
+			return "", fmt.Errorf("invalid host %q: %v", target.Hostname(), err)
+		}
+	}
+	if opts.RejectPrivate && isPrivateHost(host, ip) {
+		return "", fmt.Errorf("private address %s", host)
+	}
+	if port := target.Port(); port != "" {
+		target.Host = net.JoinHostPort(host, port)
+	} else if ip != nil && ip.To4() == nil {
+		target.Host = "[" + host + "]"
+	} else {
+		target.Host = host
+	}
+
+	target.Fragment, target.RawFragment = "", ""
+	if opts.StripTracking {
+		target.RawQuery = stripTracking(target.RawQuery)
+	}
+	return target.String(), nil
+}
+
+// hasScheme reports whether a URL as read names its scheme, the `scheme ":"`
+// prefix of RFC 3986: a letter followed by letters, digits, "+", "-" or ".".
+//
+// Notes:
+// - A host followed by a port, e.g. example.com:8080/a, names no scheme.
+// - A "://" in the path or query, e.g. example.com/r?next=https://x.org, is not a scheme.
+func hasScheme(rawURL string) bool {
+	name, rest, ok := strings.Cut(rawURL, ":")
+	if !ok || name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	port := rest
+	if end := strings.IndexAny(rest, "/?#"); end >= 0 {
+		port = rest[:end]
+	}
+	return port == "" || strings.Trim(port, "0123456789") != ""
+}
+
+// defaultScheme returns the scheme of the URLs read without one.
+func (o URLOptions) defaultScheme() string {
+	if o.DefaultScheme == "" {
+		return "https"
+	}
+	return o.DefaultScheme
+}
+
+// isPrivateHost reports whether host is localhost or ip a loopback, private,
+// link-local or unspecified address.
+func isPrivateHost(host string, ip net.IP) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified())
+}
+
+// stripTracking removes the tracking parameters of a raw query.
+func stripTracking(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var kept []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			name = strings.ToLower(name)
+			if strings.HasPrefix(name, "utm_") || trackingParams[name] {
+				continue
+			}
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&")
+}
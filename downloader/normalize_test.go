@@ -0,0 +1,65 @@
+package downloader
+
+import "testing"
+
+// Test URLs are normalized and unsupported ones rejected
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		opts     URLOptions
+		expected string // empty if the URL is rejected
+	}{
+		{"www.Example.com/Path#section", URLOptions{}, "https://www.example.com/Path"},
+		{"intranet.local/a", URLOptions{DefaultScheme: "http"}, "http://intranet.local/a"},
+		{"https://example.com/", URLOptions{DefaultScheme: "http"}, "https://example.com/"},
+		{"HTTP://EXAMPLE.com:8080/a?b=1", URLOptions{}, "http://example.com:8080/a?b=1"},
+		{"https://bücher.example/", URLOptions{}, "https://xn--bcher-kva.example/"},
+		{"https://example.com/?utm_source=x&id=7&fbclid=y", URLOptions{StripTracking: true}, "https://example.com/?id=7"},
+		{"https://example.com/?utm_source=x", URLOptions{}, "https://example.com/?utm_source=x"},
+		{"http://[::1]:8080/", URLOptions{}, "http://[::1]:8080/"},
+		{"http://10.0.0.1/", URLOptions{}, "http://10.0.0.1/"},
+		{"http://10.0.0.1/", URLOptions{RejectPrivate: true}, ""},
+		{"http://[::1]/", URLOptions{RejectPrivate: true}, ""},
+		{"http://localhost:8080/", URLOptions{RejectPrivate: true}, ""},
+		{"not a url", URLOptions{}, ""},
+		{"ftp://example.com/file", URLOptions{}, ""},
+		{"http://", URLOptions{}, ""},
+		{"  ", URLOptions{}, ""},
+		{"example.com/r?next=https://x.org", URLOptions{}, "https://example.com/r?next=https://x.org"},
+		{"localhost:8080/a", URLOptions{DefaultScheme: "http"}, "http://localhost:8080/a"},
+		{"mailto:someone@example.com", URLOptions{}, ""},
+	}
+	for _, test := range tests {
+		got, err := NormalizeURL(test.raw, test.opts)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("NormalizeURL(%q) = %q, expected an error", test.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != test.expected {
+			t.Errorf("NormalizeURL(%q) = %q (%v), expected %q", test.raw, got, err, test.expected)
+		}
+	}
+}
+
+// Test schemes are detected as RFC 3986 names them
+func TestHasScheme(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/":             true,
+		"HTTP://example.com/":              true,
+		"svn+ssh://example.com/":           true,
+		"mailto:someone@example.com":       true,
+		"example.com/r?next=https://x.org": false,
+		"example.com:8080/a":               false,
+		"example.com:8080":                 false,
+		"example.com:8080?a=1":             false,
+		"example.com":                      false,
+		"1http://example.com/":             false,
+	}
+	for raw, expected := range tests {
+		if got := hasScheme(raw); got != expected {
+			t.Errorf("hasScheme(%q) = %v, expected %v", raw, got, expected)
+		}
+	}
+}
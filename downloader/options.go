@@ -0,0 +1,140 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const MAX_WORKERS = 50 // default number of concurrent downloads
+
+// Options configures a Downloader. The zero value is usable: MAX_WORKERS
+// workers, no limits, no credentials and a client built from Transport and Redirects.
+type Options struct {
+	Workers   int // concurrent downloads, 0 means MAX_WORKERS
+	LookAhead int // URLs read ahead to dispatch the higher priorities first, 0 keeps the input order
+
+	URLs         URLOptions // normalization of the URLs read from the source
+	HTTPFallback bool       // retry URLs read without a scheme over http when https fails to connect or to handshake
+
+	MaxBodySize int64    // maximum accepted response size in bytes, 0 means unlimited
+	AllowTypes  []string // accepted Content-Type patterns (path.Match), empty accepts all
+	DenyTypes   []string // rejected Content-Type patterns, checked before AllowTypes
+
+	UserAgent   string                // User-Agent header value, empty keeps Go's default
+	Headers     []string              // extra "Name: value" request headers
+	BasicAuth   *Credential           // Basic credentials sent to every host, see AuthHosts
+	BearerToken string                // bearer token, never logged
+	Netrc       map[string]Credential // per host credentials, "" holds the default entry
+	AuthHosts   []string              // hosts, with their subdomains, BasicAuth, BearerToken and the netrc default entry are sent to; empty sends them to every host
+
+	Transport TransportOptions // ignored when HTTPClient is set
+	Redirects RedirectOptions  // ignored when HTTPClient is set
+
+	RespectRobots   bool    // honor robots.txt rules and Crawl-delay of every host
+	MaxFailureRatio float64 // failure ratio tolerated before a run counts as failed
+
+	HTTPClient *http.Client   // client used for every request, nil builds one with NewHTTPClient
+	Logger     zerolog.Logger // the zero value discards every event
+	Manifest   *Manifest      // records the outcome of every URL, nil disables it
+	Rejects    *RejectLog     // records the rows rejected by Stage 1, nil disables it
+	Hooks      Hooks          // receives the lifecycle events of every URL, nil ignores them
+	Metrics    *Metrics       // collects the counters of Run, nil creates a fresh set per run
+	Pool       *WorkerPool    // bounds the downloads of every Downloader sharing it, nil for no shared bound
+
+	// Outputs per input file (see Input.File) of a run reading several files,
+	// taking precedence over Manifest and Rejects for the URLs of these files.
+	InputManifests map[string]*Manifest
+	InputRejects   map[string]*RejectLog
+}
+
+// TransportOptions configures the HTTP transport built by NewHTTPClient.
+type TransportOptions struct {
+	Proxy               string        // http://, https:// or socks5:// proxy, empty uses the environment
+	NoProxy             []string      // hosts that bypass Proxy
+	CACertFile          string        // custom PEM CA bundle
+	ClientCertFile      string        // PEM client certificate for mTLS
+	ClientKeyFile       string        // PEM client key for mTLS
+	InsecureSkipVerify  bool          // skip TLS verification, opt-in only
+	MaxIdleConnsPerHost int           // idle connection pool size per host, 0 means MAX_WORKERS
+	DisableHTTP2        bool          // never upgrade to HTTP/2
+	KeepAlive           time.Duration // TCP keep-alive period, 0 means 30s
+	IdleConnTimeout     time.Duration // idle connection lifetime, 0 means 90s
+	DisableKeepAlives   bool          // use a new connection for every request
+}
+
+// URLOptions controls the normalization of the URLs read from a source, see NormalizeURL.
+type URLOptions struct {
+	DefaultScheme string // scheme of the URLs read without one, "http" or "https", empty means https
+	StripTracking bool   // remove tracking query parameters such as utm_source or fbclid
+	RejectPrivate bool   // reject localhost and loopback, private, link-local and unspecified IP addresses, also as redirect targets
+}
+
+// RedirectOptions is the redirect policy of the client built by NewHTTPClient.
+type RedirectOptions struct {
+	MaxRedirects    int  // redirect hops to follow, 0 means 10
+	NoFollow        bool // return the redirect response instead of following it
+	SameHost        bool // only follow redirects to the original host
+	ForbidDowngrade bool // refuse https to http redirects
+}
+
+// OptionError reports an invalid option. Option is the kebab case name also
+// used by the command line, e.g. max-size.
+type OptionError struct {
+	Option string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return e.Option + ": " + e.Reason
+}
+
+// Validate reports the first invalid option as an *OptionError.
+func (o *Options) Validate() error {
+	if o.Workers < 0 {
+		return &OptionError{"workers", "must not be negative"}
+	}
+	switch o.URLs.DefaultScheme {
+	case "", "http", "https":
+	default:
+		return &OptionError{"default-scheme", fmt.Sprintf("unsupported scheme %q, expected http or https", o.URLs.DefaultScheme)}
+	}
+	if o.HTTPFallback && o.URLs.DefaultScheme == "http" {
+		return &OptionError{"http-fallback", "requires the https default scheme"}
+	}
+	if o.LookAhead < 0 {
+		return &OptionError{"lookahead", "must not be negative"}
+	}
+	if o.MaxBodySize < 0 {
+		return &OptionError{"max-size", "must not be negative"}
+	}
+	for i, patterns := range [][]string{o.AllowTypes, o.DenyTypes} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return &OptionError{[]string{"allow-type", "deny-type"}[i], fmt.Sprintf("invalid Content-Type pattern %q: %v", pattern, err)}
+			}
+		}
+	}
+	for _, header := range o.Headers {
+		if name, _, ok := strings.Cut(header, ":"); !ok || strings.TrimSpace(name) == "" {
+			return &OptionError{"header", fmt.Sprintf("%q must be in the form \"Name: value\"", header)}
+		}
+	}
+	if (o.Transport.ClientCertFile == "") != (o.Transport.ClientKeyFile == "") {
+		return &OptionError{"client-cert", "client-cert and client-key must be given together"}
+	}
+	if o.Transport.MaxIdleConnsPerHost < 0 {
+		return &OptionError{"max-idle-conns-per-host", "must not be negative"}
+	}
+	if o.Redirects.MaxRedirects < 0 {
+		return &OptionError{"max-redirects", "must not be negative"}
+	}
+	if o.MaxFailureRatio < 0 || o.MaxFailureRatio > 1 {
+		return &OptionError{"max-failure-ratio", "must be between 0 and 1"}
+	}
+	return nil
+}
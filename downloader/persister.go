@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sink stores the downloaded bodies in Stage 3.
+type Sink interface {
+	// Write stores download and returns where it was stored, recorded as the
+	// manifest path. Stage 3 calls Write from a single goroutine.
+	Write(ctx context.Context, download Download) (string, error)
+}
+
+// DirSink writes every body to a randomly named file of a directory.
+type DirSink struct {
+	Dir string
+}
+
+// NewDirSink creates dir if it doesn't exist and returns a sink writing into it.
+func NewDirSink(dir string) (*DirSink, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("creating output directory: %v", err)
+	}
+	return &DirSink{Dir: dir}, nil
+}
+
+// Write saves the body to a new file of the sink directory and returns its
+// absolute path, so that the manifest can be verified from any directory.
+func (s *DirSink) Write(ctx context.Context, download Download) (string, error) {
+	dir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving output directory: %v", err)
+	}
+	// Generate a random file name and construct the full path
+	fileName := filepath.Join(dir, generateRandomFileName())
+
+	// Create the output file
+	file, err := os.Create(fileName)
+	if err != nil {
+		return "", fmt.Errorf("creating file: %v", err)
+	}
+
+	// Write content to the file and close it right away, Stage 3 runs for the whole pipeline
+	_, err = file.Write(download.Content)
+	file.Close()
+	if err != nil {
+		return "", fmt.Errorf("writing to file: %v", err)
+	}
+	return fileName, nil
+}
+
+// Target returns where a download would be saved. File names are random, so
+// only the directory is known in advance.
+func (s *DirSink) Target(download Download) string {
+	return filepath.Join(s.Dir, "<random>.txt")
+}
+
+// InputSinks stores every download with the sink of the input file it was read
+// from (see Input.File), e.g. one directory per input, and the other ones with Default.
+type InputSinks struct {
+	Sinks   map[string]Sink
+	Default Sink
+}
+
+// Write stores download with the sink of its input.
+func (s InputSinks) Write(ctx context.Context, download Download) (string, error) {
+	return s.sink(download.Input).Write(ctx, download)
+}
+
+// Target returns where the sink of its input would store download, "" if that sink can't tell.
+func (s InputSinks) Target(download Download) string {
+	if targeter, ok := s.sink(download.Input).(Targeter); ok {
+		return targeter.Target(download)
+	}
+	return ""
+}
+
+// sink returns the sink of input.
+func (s InputSinks) sink(input Input) Sink {
+	if sink, ok := s.Sinks[input.File]; ok {
+		return sink
+	}
+	return s.Default
+}
+
+// persistContent receives downloaded content from a channel and hands it to the sink.
+//
+// Input:
+// - ctx: Context for graceful shutdown.
+// - downloads: A channel that provides the downloaded URLs and contents.
+// - sink: Stores every download.
+// - metrics: Records the stored downloads as successes, with their duration, time to first byte and size, and the other ones as write failures.
+//
+// Output:
+// - Records every saved or failed write in the manifest, with the SHA-256 of the saved bodies.
+// - Calls Hooks.OnPersisted, or Hooks.OnFailure if the sink fails.
+// - Logs errors if the sink fails.
+// - Stops processing when the channel is closed or the context is canceled.
+func (d *Downloader) persistContent(ctx context.Context, downloads <-chan Download, sink Sink, metrics *Metrics) {
+	// Continuously listen for download results
+	for {
+		select {
+		case download, ok := <-downloads:
+			if !ok {
+				return // Exit if the channel is closed
+			}
+
+			path, err := sink.Write(ctx, download)
+			hostStats := metrics.Host(hostOf(ensureScheme(download.URL)))
+			inputStats := metrics.Input(download.Input.File)
+			if err != nil {
+				d.log.Error().Stringer("input", download.Input).Msgf("Error saving content: %v for URL: %s", err, download.URL)
+				metrics.AddFailure(FailureWrite)
+				hostStats.AddFailure(FailureWrite)
+				inputStats.Failures.Add(1)
+				metrics.AddFailedURL(download.URL, download.Input, FailureWrite, err)
+				d.record(ManifestEntry{URL: download.URL, Input: download.Input.ref(), Outcome: OutcomeFailed, Scheme: download.Scheme, FetchedURL: download.FetchedURL, FailureClass: FailureWrite, Redirects: download.Redirects, Error: err.Error()})
+				d.hooks.OnFailure(download.URL, err)
+				continue
+			}
+
+			// Track duration, time to first byte and size of the stored download
+			metrics.AddSuccess(download.Duration, download.TTFB, len(download.Content))
+			hostStats.AddSuccess(download.Duration, len(download.Content))
+			inputStats.Successes.Add(1)
+			inputStats.Bytes.Add(uint64(len(download.Content)))
+
+			// Log success
+			d.log.Info().Stringer("input", download.Input).Msgf("Saved content to %s for URL: %s", path, download.URL)
+			sum := sha256.Sum256(download.Content)
+			d.record(ManifestEntry{URL: download.URL, Input: download.Input.ref(), Outcome: OutcomeSuccess, Scheme: download.Scheme, FetchedURL: download.FetchedURL, Path: path, Bytes: int64(len(download.Content)), ContentType: download.ContentType, SHA256: hex.EncodeToString(sum[:]), Redirects: download.Redirects})
+			d.hooks.OnPersisted(download, path)
+
+		case <-ctx.Done(): // Handle shutdown scenario
+			d.log.Info().Msgf("Stage 3: Context canceled. Stopping file write.")
+			return
+		}
+	}
+}
+
+// generateRandomFilename creates a unique filename using a random string and a timestamp
+func generateRandomFileName() string {
+	return fmt.Sprintf("%d-%v.txt", rand.Intn(1000000), time.Now().UnixNano())
+}
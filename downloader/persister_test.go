@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test successful persistence
+func TestPersistContent_Success(t *testing.T) {
+	ctx := context.Background()
+	contentChan := make(chan Download, 1)
+
+	// Send mock data
+	contentChan <- Download{URL: "http://example.com", Content: []byte("test content")}
+	close(contentChan)
+	sink, err := NewDirSink(filepath.Join(t.TempDir(), "downloads"))
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	metrics := &Metrics{}
+	newTestDownloader(t, Options{}).persistContent(ctx, contentChan, sink, metrics)
+
+	// Verify results
+	if metrics.SuccessCount.Load() != 1 || metrics.TotalBytes.Load() != 12 || metrics.Input("").Successes.Load() != 1 {
+		t.Errorf("Expected the stored download to be counted as a success, got %d (%d bytes)", metrics.SuccessCount.Load(), metrics.TotalBytes.Load())
+	}
+	files, err := os.ReadDir(sink.Dir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected 1 file, got %d", len(files))
+	}
+}
+
+// Test handling of closed channel
+func TestPersistContent_ClosedChannel(t *testing.T) {
+	ctx := context.Background()
+	contentChan := make(chan Download)
+	close(contentChan) // Close the channel before calling the function
+
+	// Returns right away without panicking
+	newTestDownloader(t, Options{}).persistContent(ctx, contentChan, &DirSink{Dir: t.TempDir()}, &Metrics{})
+}
+
+// Test handling of context cancellation
+func TestPersistContent_ContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	contentChan := make(chan Download)
+
+	// Cancel the context
+	cancel()
+
+	// Returns although the channel stays open
+	newTestDownloader(t, Options{}).persistContent(ctx, contentChan, &DirSink{Dir: t.TempDir()}, &Metrics{})
+}
+
+// Test handling of file creation failure
+func TestPersistContent_FileCreationFailure(t *testing.T) {
+	ctx := context.Background()
+	contentChan := make(chan Download, 1)
+
+	// Mock invalid directory
+	sink := &DirSink{Dir: filepath.Join(t.TempDir(), "missing")}
+	contentChan <- Download{URL: "http://example.com", Content: []byte("test content")}
+	close(contentChan)
+
+	metrics := &Metrics{}
+	newTestDownloader(t, Options{}).persistContent(ctx, contentChan, sink, metrics)
+
+	if metrics.SuccessCount.Load() != 0 || metrics.FailureCounts()[FailureWrite] != 1 {
+		t.Errorf("Expected the download to be counted in the write failure class, got %v", metrics.FailureCounts())
+	}
+	if metrics.TotalBytes.Load() != 0 || metrics.Latency.Count() != 0 || metrics.Size.Count() != 0 {
+		t.Errorf("Expected no size or latency sample for the unsaved download, got %d bytes, %d samples", metrics.TotalBytes.Load(), metrics.Latency.Count())
+	}
+}
@@ -0,0 +1,183 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Plan is what a run would do, computed by Downloader.Plan without downloading.
+type Plan struct {
+	Read           int            `json:"read"`            // URLs read from the source, valid or not
+	Duplicates     int            `json:"duplicates"`      // URLs read again from the same input after their first occurrence
+	Hosts          map[string]int `json:"hosts"`           // planned URLs per host
+	EstimatedBytes int64          `json:"estimated_bytes"` // sum of the known sizes
+	UnknownSizes   int            `json:"unknown_sizes"`   // planned URLs without a known size
+	URLs           []PlannedURL   `json:"urls"`
+	Rejected       []RowError     `json:"rejected"`
+}
+
+// PlannedURL is a URL a run would download.
+type PlannedURL struct {
+	URL         string `json:"url"`
+	Input       *Input `json:"input,omitempty"` // row the URL was first read from
+	Priority    int    `json:"priority,omitempty"`
+	Size        int64  `json:"size"` // Content-Length of the HEAD response, -1 if unknown
+	ContentType string `json:"content_type,omitempty"`
+	Target      string `json:"target,omitempty"` // where the sink would store it, see Targeter
+}
+
+// Targeter is implemented by sinks that can tell where a download would be
+// stored without storing anything, for Downloader.Plan. Only the URL and input
+// of the download are known.
+type Targeter interface {
+	Target(download Download) string
+}
+
+// Plan reads source to the end and reports what Run would download, without
+// downloading or writing anything.
+//
+// Input:
+// - ctx: Context for graceful shutdown.
+// - source: Provides the URLs, rows of a CSVSource are rejected with their line numbers.
+// - sink: Names the target of every URL if it implements Targeter, may be nil.
+// - head: Issue a HEAD request per URL to learn its size and Content-Type.
+//
+// Output:
+// - Returns the Plan; URLs that are invalid, disallowed by robots.txt or, with head, rejected by the size or Content-Type limits or failing are listed in Plan.Rejected.
+// - Returns an error if source failed or ctx is done.
+//
+// Notes:
+// - URLs are normalized as by Run (see NormalizeURL) and deduplicated; the first occurrence is planned.
+// - With Options.RespectRobots, robots.txt is fetched once per host but Crawl-delay is not waited for.
+// - HEAD requests run with up to Options.Workers at a time; servers refusing HEAD (405, 501) leave the size unknown.
+func (d *Downloader) Plan(ctx context.Context, source Source, sink Sink, head bool) (Plan, error) {
+	plan := Plan{Hosts: make(map[string]int), URLs: []PlannedURL{}, Rejected: []RowError{}}
+	jobs, err := d.planSource(ctx, source, &plan)
+	if err != nil {
+		return plan, err
+	}
+
+	// Check every URL concurrently, keeping the source order
+	checked := make([]PlannedURL, len(jobs))
+	reasons := make([]string, len(jobs))
+	semaphore := make(chan struct{}, d.opts.Workers)
+	var wg sync.WaitGroup
+	for i, item := range jobs {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			checked[i], reasons[i] = d.planURL(ctx, item.url, head)
+			checked[i].Input, checked[i].Priority = item.ref(), item.priority
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return plan, err
+	}
+
+	targeter, _ := sink.(Targeter)
+	for i, planned := range checked {
+		if reasons[i] != "" {
+			plan.Rejected = append(plan.Rejected, RowError{Input: jobs[i].Input, Reason: reasons[i]})
+			continue
+		}
+		if targeter != nil {
+			planned.Target = targeter.Target(Download{URL: planned.URL, Input: jobs[i].Input})
+		}
+		if planned.Size < 0 {
+			plan.UnknownSizes++
+		} else {
+			plan.EstimatedBytes += planned.Size
+		}
+		plan.Hosts[hostOf(ensureScheme(planned.URL))]++
+		plan.URLs = append(plan.URLs, planned)
+	}
+	return plan, nil
+}
+
+// planSource reads the valid and unique URLs of source, normalized, recording
+// the read, duplicate and invalid ones in plan.
+func (d *Downloader) planSource(ctx context.Context, source Source, plan *Plan) ([]job, error) {
+	var jobs []job
+	seen := make(map[inputURL]bool)
+	err := readRows(ctx, source, func(row sourceRow) bool {
+		plan.Read++
+		url, rowErr := normalizeRow(row, d.opts.URLs)
+		if rowErr != nil {
+			plan.Rejected = append(plan.Rejected, *rowErr)
+		} else if seen[inputURL{row.File, url}] {
+			plan.Duplicates++
+		} else {
+			seen[inputURL{row.File, url}] = true
+			jobs = append(jobs, job{Input: row.Input, url: url, priority: row.priority})
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		return jobs, err
+	}
+	return jobs, ctx.Err()
+}
+
+// planURL checks robots.txt and, with head, the size and Content-Type of a URL.
+// It returns the reason the URL would not be downloaded, "" if it would.
+func (d *Downloader) planURL(ctx context.Context, url string, head bool) (PlannedURL, string) {
+	planned := PlannedURL{URL: url, Size: -1}
+	if _, allowed, err := d.robots.permits(ctx, ensureScheme(url)); err != nil {
+		return planned, err.Error()
+	} else if !allowed {
+		return planned, "blocked by robots.txt"
+	}
+	if !head {
+		return planned, ""
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, ensureScheme(url), nil)
+	if err != nil {
+		return planned, err.Error()
+	}
+	d.applyRequestOptions(req)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return planned, fmt.Sprintf("%s: %v", classifyFailure(err), err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		return planned, "" // HEAD not supported, the size stays unknown
+	case resp.StatusCode != http.StatusOK:
+		err := &httpStatusError{code: resp.StatusCode}
+		return planned, fmt.Sprintf("%s: %v", classifyFailure(err), err)
+	}
+
+	planned.ContentType = resp.Header.Get("Content-Type")
+	if err := d.checkContentType(planned.ContentType); err != nil {
+		return planned, err.Error()
+	}
+	planned.Size = resp.ContentLength
+	if maxBodySize := d.opts.MaxBodySize; maxBodySize > 0 && planned.Size > maxBodySize {
+		return planned, fmt.Sprintf("Content-Length %d exceeds max size %d", planned.Size, maxBodySize)
+	}
+	return planned, ""
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p Plan) WriteJSON(filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false) // keep the <random> of the targets readable
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
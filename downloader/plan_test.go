@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test the plan dedupes URLs, estimates sizes with HEAD requests and rejects what a run would not save
+func TestPlan(t *testing.T) {
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			gets++
+		}
+		switch r.URL.Path {
+		case "/small":
+			w.Header().Set("Content-Length", "5")
+		case "/large":
+			w.Header().Set("Content-Length", "500")
+		case "/no-head":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "downloads")
+	source := SliceSource{server.URL + "/small", server.URL + "/large", server.URL + "/small", server.URL + "/no-head", server.URL + "/missing", "ftp://example.com/file"}
+	d := newTestDownloader(t, Options{MaxBodySize: 100})
+	plan, err := d.Plan(context.Background(), source, &DirSink{Dir: dir}, true)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+
+	if plan.Read != 6 || plan.Duplicates != 1 || len(plan.URLs) != 2 || len(plan.Rejected) != 3 {
+		t.Fatalf("Expected 6 read, 1 duplicate, 2 planned and 3 rejected, got %+v", plan)
+	}
+	if plan.URLs[0].Size != 5 || plan.URLs[1].Size != -1 || plan.EstimatedBytes != 5 || plan.UnknownSizes != 1 {
+		t.Errorf("Expected sizes 5 and unknown, got %+v", plan.URLs)
+	}
+	if plan.URLs[0].Target != filepath.Join(dir, "<random>.txt") {
+		t.Errorf("Expected a target in %s, got %s", dir, plan.URLs[0].Target)
+	}
+	if gets != 0 {
+		t.Errorf("Expected only HEAD requests, got %d GET requests", gets)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the output directory not to be created")
+	}
+}
+
+// Test the CSV rows rejected by the plan carry their line numbers
+func TestPlan_CSVLines(t *testing.T) {
+	filePath, err := createTempCSV("url\nwww.a.com\nwww.a.com\n\"www.b.com\",extra\n")
+	if err != nil {
+		t.Fatalf("Failed to create CSV: %v", err)
+	}
+	defer os.Remove(filePath)
+
+	plan, err := newTestDownloader(t, Options{}).Plan(context.Background(), CSVSource{Path: filePath}, nil, false)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if len(plan.URLs) != 1 || plan.Duplicates != 1 || len(plan.Rejected) != 1 || plan.Rejected[0].Line != 4 {
+		t.Errorf("Expected 1 planned URL, 1 duplicate and line 4 rejected, got %+v", plan)
+	}
+}
@@ -0,0 +1,48 @@
+package downloader
+
+import "context"
+
+// WorkerPool bounds the concurrent downloads of several Downloaders sharing it
+// through Options.Pool, e.g. the jobs of a server, on top of the Workers of
+// every one of them.
+type WorkerPool struct {
+	slots chan struct{}
+}
+
+// NewWorkerPool returns a pool of size concurrent downloads, MAX_WORKERS if size is not positive.
+func NewWorkerPool(size int) *WorkerPool {
+	if size <= 0 {
+		size = MAX_WORKERS
+	}
+	return &WorkerPool{slots: make(chan struct{}, size)}
+}
+
+// Size returns the number of concurrent downloads of the pool.
+func (p *WorkerPool) Size() int {
+	return cap(p.slots)
+}
+
+// Busy returns the number of downloads in progress in the pool.
+func (p *WorkerPool) Busy() int {
+	return len(p.slots)
+}
+
+// acquire waits for a free slot, false if ctx is done first. A nil pool never waits.
+func (p *WorkerPool) acquire(ctx context.Context) bool {
+	if p == nil {
+		return true
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees the slot taken by acquire.
+func (p *WorkerPool) release() {
+	if p != nil {
+		<-p.slots
+	}
+}
@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test downloaders sharing a pool never download more URLs at once than its size
+func TestRun_WorkerPool(t *testing.T) {
+	var active, peak atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := active.Add(1)
+		defer active.Add(-1)
+		for {
+			last := peak.Load()
+			if current <= last || peak.CompareAndSwap(last, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("mock data"))
+	}))
+	defer server.Close()
+
+	pool := NewWorkerPool(2)
+	var wg sync.WaitGroup
+	results := make([]Result, 3)
+	for i := range results {
+		d := newTestDownloader(t, Options{Workers: 4, Pool: pool})
+		urls := SliceSource{server.URL + "/a", server.URL + "/b", server.URL + "/c", server.URL + "/d"}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = d.Run(context.Background(), urls, &DirSink{Dir: t.TempDir()})
+		}()
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if result.Successes != 4 {
+			t.Errorf("Run %d: expected 4 successes, got %+v", i, result)
+		}
+	}
+	if peak.Load() > 2 {
+		t.Errorf("Expected at most 2 concurrent downloads, got %d", peak.Load())
+	}
+	if pool.Busy() != 0 {
+		t.Errorf("Expected every slot to be released, got %d busy", pool.Busy())
+	}
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+const METRICS_PREFIX = "url_downloader_"
+
+var (
+	// Bucket bounds exposed for the latency and time to first byte histograms (in seconds)
+	durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	// Bucket bounds exposed for the response size histogram (in bytes)
+	sizeBuckets = []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20, 100 << 20, 1 << 30}
+)
+
+// MetricsHandler serves m in the Prometheus text format at /metrics.
+func MetricsHandler(m *Metrics) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WritePrometheus(w)
+	})
+	return mux
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	writeMetric(w, "urls_read_total", "counter", "URLs read from the input file.", "", m.TotalURLs.Load())
+	writeMetric(w, "in_flight", "gauge", "Downloads in progress.", "", m.InFlight.Load())
+	writeMetric(w, "successes_total", "counter", "Successful downloads.", "", m.SuccessCount.Load())
+	writeMetric(w, "skipped_total", "counter", "Responses rejected by the size or Content-Type limits.", "", m.SkippedCount.Load())
+	writeMetric(w, "blocked_total", "counter", "URLs disallowed by robots.txt.", "", m.BlockedCount.Load())
+	writeMetric(w, "bytes_total", "counter", "Bytes of successful downloads.", "", m.TotalBytes.Load())
+
+	fmt.Fprintf(w, "# HELP %sfailures_total Failed downloads by failure class.\n# TYPE %sfailures_total counter\n", METRICS_PREFIX, METRICS_PREFIX)
+	counts := m.FailureCounts()
+	for _, class := range sortedKeys(counts) {
+		fmt.Fprintf(w, "%sfailures_total{class=%q} %d\n", METRICS_PREFIX, class, counts[class])
+	}
+
+	fmt.Fprintf(w, "# HELP %squeue_depth Items waiting in a pipeline channel.\n# TYPE %squeue_depth gauge\n", METRICS_PREFIX, METRICS_PREFIX)
+	queues := m.QueueDepths()
+	for _, queue := range sortedKeys(queues) {
+		fmt.Fprintf(w, "%squeue_depth{queue=%q} %d\n", METRICS_PREFIX, queue, queues[queue])
+	}
+
+	writeHistogram(w, "download_duration_seconds", "Duration of successful downloads.", &m.Latency, durationBuckets, 1e9)
+	writeHistogram(w, "ttfb_seconds", "Time to first byte of successful downloads.", &m.TTFB, durationBuckets, 1e9)
+	writeHistogram(w, "response_size_bytes", "Body size of successful downloads.", &m.Size, sizeBuckets, 1)
+}
+
+// writeMetric writes a single sample with its HELP and TYPE lines.
+func writeMetric[T uint64 | int64](w io.Writer, name string, kind string, help string, labels string, value T) {
+	fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s %s\n%s%s%s %d\n", METRICS_PREFIX, name, help, METRICS_PREFIX, name, kind, METRICS_PREFIX, name, labels, value)
+}
+
+// writeHistogram writes h with the given bucket bounds. unit is the number of
+// recorded units per exposed unit, e.g. 1e9 for nanoseconds exposed as seconds.
+func writeHistogram(w io.Writer, name string, help string, h *Histogram, bounds []float64, unit float64) {
+	fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s histogram\n", METRICS_PREFIX, name, help, METRICS_PREFIX, name)
+	for _, bound := range bounds {
+		fmt.Fprintf(w, "%s%s_bucket{le=\"%g\"} %d\n", METRICS_PREFIX, name, bound, h.CountAtOrBelow(uint64(bound*unit)))
+	}
+	count := h.Count()
+	fmt.Fprintf(w, "%s%s_bucket{le=\"+Inf\"} %d\n", METRICS_PREFIX, name, count)
+	fmt.Fprintf(w, "%s%s_sum %g\n", METRICS_PREFIX, name, float64(h.Sum())/unit)
+	fmt.Fprintf(w, "%s%s_count %d\n", METRICS_PREFIX, name, count)
+}
+
+// sortedKeys returns the keys of a map in increasing order.
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
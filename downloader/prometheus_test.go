@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the text exposition of counters, failure classes and histograms
+func TestMetrics_WritePrometheus(t *testing.T) {
+	m := &Metrics{}
+	m.TotalURLs.Add(3)
+	m.AddSuccess(20*time.Millisecond, 5*time.Millisecond, 2048)
+	m.AddFailure("http_404")
+
+	var out strings.Builder
+	m.setQueues(func() map[string]int { return map[string]int{"urls": 4} })
+	m.WritePrometheus(&out)
+	text := out.String()
+
+	for _, expected := range []string{
+		"url_downloader_urls_read_total 3\n",
+		"url_downloader_successes_total 1\n",
+		"url_downloader_failures_total{class=\"http_404\"} 1\n",
+		"url_downloader_queue_depth{queue=\"urls\"} 4\n",
+		"url_downloader_download_duration_seconds_bucket{le=\"0.01\"} 0\n",
+		"url_downloader_download_duration_seconds_bucket{le=\"0.025\"} 1\n",
+		"url_downloader_download_duration_seconds_count 1\n",
+		"url_downloader_response_size_bytes_bucket{le=\"+Inf\"} 1\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected output to contain %q", expected)
+		}
+	}
+}
+
+// Test the handler serves /metrics
+func TestMetricsHandler(t *testing.T) {
+	m := &Metrics{}
+	m.InFlight.Add(2)
+	server := httptest.NewServer(MetricsHandler(m))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "url_downloader_in_flight 2\n") {
+		t.Errorf("Expected in_flight gauge in scrape, got:\n%s", body)
+	}
+}
@@ -0,0 +1,482 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Source feeds the URLs of a run to Stage 1.
+type Source interface {
+	// Read sends every URL to urls and returns once the source is exhausted
+	// or ctx is done. It must not close urls.
+	Read(ctx context.Context, urls chan<- string) error
+}
+
+// CSVSource reads URLs from a CSV file with a header row and one URL per row.
+// With a `priority` column in the header, every row holds a URL (the `url`
+// column, or the other one) and its priority.
+type CSVSource struct {
+	Path string
+}
+
+// JSONLSource reads URLs from a JSON Lines file, one `{"url": ..., "priority": ...}`
+// object per line; the priority is optional and blank lines are ignored.
+type JSONLSource struct {
+	Path string
+}
+
+// SliceSource feeds a fixed list of URLs.
+type SliceSource []string
+
+// MultiSource reads several sources one after the other as a single run, e.g.
+// several input files; the URLs keep the file and line of their source.
+type MultiSource []Source
+
+// FileSource returns the source reading filePath: a JSONLSource for a .jsonl
+// file, a CSVSource otherwise.
+func FileSource(filePath string) Source {
+	if strings.EqualFold(filepath.Ext(filePath), ".jsonl") {
+		return JSONLSource{Path: filePath}
+	}
+	return CSVSource{Path: filePath}
+}
+
+// Read reads URLs from the CSV file and sends them to a channel for processing.
+//
+// Input:
+// - ctx: Context for graceful shutdown.
+// - urls: A channel to send valid URLs for further processing.
+//
+// Expected CSV Format:
+// - First row is treated as a header, it only names the columns.
+// - Each subsequent row contains a single URL, or a URL and its priority with a `priority` column.
+//
+// Output:
+// - Sends the URL of every row with a single column to urls, as read; Downloader.Run validates and normalizes them (see NormalizeURL).
+// - Returns an error if the file cannot be opened.
+// - Stops processing when the context is canceled.
+//
+// Notes:
+// - Logs errors for invalid rows, with their line numbers, but continues processing.
+// - Uses a buffered reader for efficient file reading.
+func (s CSVSource) Read(ctx context.Context, urls chan<- string) error {
+	return sendRows(ctx, s, urls)
+}
+
+// sendRows sends the URL of every valid row of source to urls, logging the invalid ones.
+func sendRows(ctx context.Context, source lineSource, urls chan<- string) error {
+	log := zerolog.Ctx(ctx)
+	return source.readLines(ctx, func(row sourceRow) bool {
+		if row.err != nil {
+			log.Error().Msgf("Skipping invalid row %s: %s", row.Input, row.err.Reason) // Log and skip malformed rows
+			return true
+		}
+
+		// Send URL to channel or exit if context is canceled
+		select {
+		case urls <- row.url: // Send URL to channel
+			return true
+		case <-ctx.Done(): // Handle shutdown scenario
+			log.Error().Msgf("Stage 1: Context canceled./Shutdown initiated. Stopping file read")
+			return false
+		}
+	})
+}
+
+// readLines calls fn for every data row of the CSV file with its input.
+// A missing header is logged and ends the file, as for an empty file.
+func (s CSVSource) readLines(ctx context.Context, fn func(row sourceRow) bool) error {
+	err := readCSVRows(s.Path, fn)
+	if errors.Is(err, errCSVHeader) {
+		zerolog.Ctx(ctx).Error().Msgf("Failed to read CSV Header: %v", err) // Log error if header read fails
+		return nil
+	}
+	return err
+}
+
+// Read sends the URL of every valid line of the JSONL file to urls, logging the invalid lines.
+func (s JSONLSource) Read(ctx context.Context, urls chan<- string) error {
+	return sendRows(ctx, s, urls)
+}
+
+// readLines calls fn for every non blank line of the JSONL file with its input.
+func (s JSONLSource) readLines(ctx context.Context, fn func(row sourceRow) bool) error {
+	return readJSONLRows(s.Path, fn)
+}
+
+// Read sends the URLs of every source to urls, see readLines.
+func (s MultiSource) Read(ctx context.Context, urls chan<- string) error {
+	return sendRows(ctx, s, urls)
+}
+
+// readLines calls fn for the rows of every source in turn.
+//
+// Notes:
+// - A source that fails, e.g. a file that cannot be opened, doesn't stop the next ones; the errors are joined.
+func (s MultiSource) readLines(ctx context.Context, fn func(row sourceRow) bool) error {
+	var errs []error
+	stopped := false
+	for _, source := range s {
+		err := readRows(ctx, source, func(row sourceRow) bool {
+			stopped = !fn(row)
+			return !stopped
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if stopped || ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Read sends every URL of the slice to urls.
+func (s SliceSource) Read(ctx context.Context, urls chan<- string) error {
+	for _, url := range s {
+		select {
+		case urls <- url:
+		case <-ctx.Done():
+			zerolog.Ctx(ctx).Error().Msgf("Stage 1: Context canceled./Shutdown initiated. Stopping retry")
+			return nil
+		}
+	}
+	return nil
+}
+
+// CountCSVRows counts the data rows of a CSV file, i.e. the records after the header.
+// It is used to estimate the progress of a run before Stage 1 streams the file.
+//
+// Notes:
+// - A .jsonl file counts its non blank lines.
+func CountCSVRows(filePath string) (uint64, error) {
+	if strings.EqualFold(filepath.Ext(filePath), ".jsonl") {
+		return countJSONLRows(filePath)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = -1 // Row validation is left to CSVSource
+	var rows uint64
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		rows++
+	}
+	if rows > 0 {
+		rows-- // header
+	}
+	return rows, nil
+}
+
+// Input locates a URL in the input of a run. It is carried from Stage 1 to
+// Stage 3 so that logs, reports, the manifest and the rejects file reference
+// the row a URL was read from.
+type Input struct {
+	File string `json:"file,omitempty"` // input file, empty if the source isn't a file
+	Line int    `json:"line,omitempty"` // line of the row in File, 0 if unknown
+	Row  int    `json:"row,omitempty"`  // index of the row in the source, from 1
+	Text string `json:"text"`           // row as read
+
+	SHA256 string `json:"sha256,omitempty"` // expected SHA-256 of the body, given by the row
+}
+
+// String returns `file:line`, or `row n` if the line is unknown.
+func (in Input) String() string {
+	switch {
+	case in.File != "" && in.Line > 0:
+		return fmt.Sprintf("%s:%d", in.File, in.Line)
+	case in.Row > 0:
+		return fmt.Sprintf("row %d", in.Row)
+	}
+	return ""
+}
+
+// ref returns in for an omitempty field, nil if it is unknown.
+func (in Input) ref() *Input {
+	if in == (Input{}) {
+		return nil
+	}
+	return &in
+}
+
+// RowError is an input row that cannot be downloaded.
+type RowError struct {
+	Input
+	Reason string `json:"reason"`
+}
+
+// sourceRow is a row read by Stage 1 with its URL and priority, or the reason the row is invalid.
+type sourceRow struct {
+	Input
+	url      string
+	priority int // higher is downloaded first, see Options.LookAhead
+	err      *RowError
+}
+
+// lineSource is implemented by sources that know the file and line of every
+// URL, so that they are reported with it.
+type lineSource interface {
+	readLines(ctx context.Context, fn func(row sourceRow) bool) error
+}
+
+// errCSVHeader reports a CSV file whose header row cannot be read, e.g. an empty file.
+var errCSVHeader = errors.New("reading CSV header")
+
+// readRows runs source and calls fn for every URL it reads, with its row
+// index, and its file and line for a lineSource. It stops early if fn returns false.
+func readRows(ctx context.Context, source Source, fn func(row sourceRow) bool) error {
+	if lines, ok := source.(lineSource); ok {
+		return lines.readLines(ctx, fn)
+	}
+
+	read := make(chan string)
+	sourceErr := make(chan error, 1)
+	go func() {
+		defer close(read)
+		sourceErr <- source.Read(ctx, read)
+	}()
+	rows := 0
+	for url := range read {
+		rows++
+		if !fn(sourceRow{Input: Input{Row: rows, Text: url}, url: url}) {
+			for range read { // let the source notice the cancellation
+			}
+		}
+	}
+	return <-sourceErr
+}
+
+// ValidateCSV checks every row of a CSV or JSONL file the way Downloader.Run
+// reads it (see FileSource), without downloading anything.
+//
+// Input:
+// - filePath: CSV file with a header row and one URL per row, or JSONL file.
+// - opts: the URL normalization options of the run.
+//
+// Output:
+// - rows: number of data rows, valid or not.
+// - invalid: the rows a run would reject, with their line numbers.
+// - Returns an error if the file cannot be opened or its header cannot be read.
+func ValidateCSV(filePath string, opts URLOptions) (rows int, invalid []RowError, err error) {
+	err = FileSource(filePath).(lineSource).readLines(context.Background(), func(row sourceRow) bool {
+		rows++
+		if _, rowErr := normalizeRow(row, opts); rowErr != nil {
+			invalid = append(invalid, *rowErr)
+		}
+		return true
+	})
+	return rows, invalid, err
+}
+
+// normalizeRow returns the normalized URL of a row, or why it cannot be downloaded.
+func normalizeRow(row sourceRow, opts URLOptions) (string, *RowError) {
+	if row.err != nil {
+		return "", row.err
+	}
+	url, err := NormalizeURL(row.url, opts)
+	if err != nil {
+		return "", &RowError{Input: row.Input, Reason: err.Error()}
+	}
+	return url, nil
+}
+
+// readCSVRows calls fn for every data row of a CSV file with its input;
+// rows without the columns of the header (see csvColumns), with an invalid
+// priority or checksum are passed as invalid. It stops early if fn returns false.
+func readCSVRows(filePath string, fn func(row sourceRow) bool) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = -1 // Report the column count instead of failing the row
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errCSVHeader, err)
+	}
+	urlColumn, priorityColumn, sha256Column, columns := csvColumns(header)
+
+	for rows := 1; ; rows++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			in := Input{File: filePath, Line: parseErr.StartLine, Row: rows}
+			if !fn(sourceRow{Input: in, err: &RowError{Input: in, Reason: parseErr.Err.Error()}}) {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := sourceRow{Input: Input{File: filePath, Line: line, Row: rows, Text: strings.Join(record, ",")}}
+		if len(record) != columns {
+			row.err = &RowError{Input: row.Input, Reason: fmt.Sprintf("expected %d column%s, got %d", columns, plural(columns), len(record))}
+		} else {
+			row.url = record[urlColumn]
+			if priorityColumn >= 0 {
+				if row.priority, err = parsePriority(record[priorityColumn]); err != nil {
+					row.err = &RowError{Input: row.Input, Reason: err.Error()}
+				}
+			}
+			if sha256Column >= 0 && row.err == nil {
+				if row.SHA256, err = parseSHA256(record[sha256Column]); err != nil {
+					row.err = &RowError{Input: row.Input, Reason: err.Error()}
+				}
+			}
+		}
+		if !fn(row) {
+			return nil
+		}
+	}
+}
+
+// csvColumns returns the URL, priority and expected SHA-256 columns named by
+// a CSV header, -1 for a missing one, and the number of columns of every row.
+//
+// Notes:
+// - Without a `priority` or `sha256` column, rows hold a single URL whatever the header says.
+// - With one, the URL is in the `url` column, or in the first other column.
+func csvColumns(header []string) (urlColumn int, priorityColumn int, sha256Column int, columns int) {
+	urlColumn, priorityColumn, sha256Column, columns = -1, -1, -1, 1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "priority":
+			priorityColumn, columns = i, len(header)
+		case "sha256":
+			sha256Column, columns = i, len(header)
+		case "url":
+			urlColumn = i
+		}
+	}
+	if columns == 1 {
+		return 0, -1, -1, 1
+	}
+	for i := 0; urlColumn < 0; i++ {
+		if i != priorityColumn && i != sha256Column {
+			urlColumn = i
+		}
+	}
+	return urlColumn, priorityColumn, sha256Column, columns
+}
+
+// parsePriority parses the priority of a row, an empty value is 0.
+func parsePriority(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid priority %q", value)
+	}
+	return priority, nil
+}
+
+// parseSHA256 parses the expected SHA-256 of a row, an empty value checks nothing.
+func parseSHA256(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", nil
+	}
+	if _, err := hex.DecodeString(value); err != nil || len(value) != 2*sha256.Size {
+		return "", fmt.Errorf("invalid sha256 %q", value)
+	}
+	return value, nil
+}
+
+// plural returns "s" unless n is 1.
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// jsonlRow is a line of a JSONL input file.
+type jsonlRow struct {
+	URL      string `json:"url"`
+	Priority int    `json:"priority"`
+	SHA256   string `json:"sha256"`
+}
+
+// readJSONLRows calls fn for every non blank line of a JSONL file with its
+// input; lines that are not a JSON object with a url, or with an invalid
+// sha256, are passed as invalid.
+// It stops early if fn returns false.
+func readJSONLRows(filePath string, fn func(row sourceRow) bool) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLLine)
+	for line, rows := 1, 0; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		rows++
+		row := sourceRow{Input: Input{File: filePath, Line: line, Row: rows, Text: text}}
+		var decoded jsonlRow
+		if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+			row.err = &RowError{Input: row.Input, Reason: fmt.Sprintf("invalid JSON: %v", err)}
+		} else if decoded.URL == "" {
+			row.err = &RowError{Input: row.Input, Reason: "missing url"}
+		} else if row.SHA256, err = parseSHA256(decoded.SHA256); err != nil {
+			row.err = &RowError{Input: row.Input, Reason: err.Error()}
+		} else {
+			row.url, row.priority = decoded.URL, decoded.Priority
+		}
+		if !fn(row) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// maxJSONLLine is the longest line accepted in a JSONL input file.
+const maxJSONLLine = 1024 * 1024
+
+// countJSONLRows counts the non blank lines of a JSONL file.
+func countJSONLRows(filePath string) (uint64, error) {
+	var rows uint64
+	err := readJSONLRows(filePath, func(sourceRow) bool {
+		rows++
+		return true
+	})
+	return rows, err
+}
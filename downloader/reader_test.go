@@ -0,0 +1,195 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Helper function to create a temporary CSV file for testing
+func createTempCSV(content string) (string, error) {
+	file, err := os.CreateTemp("", "test_*.csv")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	if err != nil {
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// readAll runs source to completion and returns the URLs it sent
+func readAll(source Source, ctx context.Context) ([]string, error) {
+	urlChan := make(chan string, 50)
+	err := source.Read(ctx, urlChan)
+	close(urlChan)
+	var urls []string
+	for url := range urlChan {
+		urls = append(urls, url)
+	}
+	return urls, err
+}
+
+// Test reading a valid CSV file
+func TestReadCSVFile_Valid(t *testing.T) {
+	filePath := "../testdata/valid.csv"
+	actualURLs, err := readAll(CSVSource{Path: filePath}, context.Background())
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	expectedURLs := []string{"https://example.com", "https://google.com"}
+	if len(actualURLs) != len(expectedURLs) {
+		t.Errorf("Expected %d URLs, got %d", len(expectedURLs), len(actualURLs))
+	}
+}
+
+// Test reading an empty CSV file
+func TestReadCSVFile_EmptyFile(t *testing.T) {
+	filePath := "../testdata/empty.csv"
+	actualURLs, _ := readAll(CSVSource{Path: filePath}, context.Background())
+	// Verify results
+	if len(actualURLs) != 0 {
+		t.Errorf("Expected 0 URLs, got %d", len(actualURLs))
+	}
+}
+
+// Test handling an invalid format (extra columns)
+func TestReadCSVFile_InvalidFormat(t *testing.T) {
+	filePath := "../testdata/invalid.csv"
+	actualURLs, _ := readAll(CSVSource{Path: filePath}, context.Background())
+	// Verify results
+	if len(actualURLs) != 0 {
+		t.Errorf("Expected 0 URLs, got %d", len(actualURLs))
+	}
+}
+
+// Test reading with context cancellation
+func TestReadCSVFile_ContextCancelled(t *testing.T) {
+	filePath, err := createTempCSV("url\nhttps://example.com\nhttps://google.com\n")
+	if err != nil {
+		t.Fatalf("Failed to create CSV file: %v", err)
+	}
+	defer os.Remove(filePath)
+
+	urlChan := make(chan string) // Unbuffered, nobody receives
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond) // Simulate early cancel
+		cancel()
+	}()
+
+	done := make(chan error)
+	go func() { done <- CSVSource{Path: filePath}.Read(ctx, urlChan) }()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Read should return once the context is canceled")
+	}
+}
+
+// Test reading a non-existent file
+func TestReadCSVFile_NonExistentFile(t *testing.T) {
+	if _, err := readAll(CSVSource{Path: "non_existent_file.csv"}, context.Background()); err == nil {
+		t.Errorf("Expected error when file does not exist")
+	}
+}
+
+// Test the fixed list source used by retries
+func TestSliceSource(t *testing.T) {
+	urls, err := readAll(SliceSource{"www.a.com", "www.b.com"}, context.Background())
+	if err != nil || len(urls) != 2 {
+		t.Errorf("Expected 2 URLs, got %v (%v)", urls, err)
+	}
+}
+
+// Test counting data rows of a CSV file
+func TestCountCSVRows(t *testing.T) {
+	rows, err := CountCSVRows("../testdata/valid.csv")
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if rows != 2 {
+		t.Errorf("Expected 2 rows, got %d", rows)
+	}
+}
+
+// Test the invalid rows of a CSV file are reported with their file, line and row
+func TestValidateCSV(t *testing.T) {
+	filePath, err := createTempCSV("url\nwww.a.com\nftp://b.com/file\n\"www.c.com\",extra\nhttp://\nhttps://d.com/page\n")
+	if err != nil {
+		t.Fatalf("Failed to create CSV: %v", err)
+	}
+	defer os.Remove(filePath)
+
+	rows, invalid, err := ValidateCSV(filePath, URLOptions{})
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if rows != 5 {
+		t.Errorf("Expected 5 rows, got %d", rows)
+	}
+	expected := []RowError{
+		{Input{File: filePath, Line: 3, Row: 2, Text: "ftp://b.com/file"}, `unsupported scheme "ftp"`},
+		{Input{File: filePath, Line: 4, Row: 3, Text: "www.c.com,extra"}, "expected 1 column, got 2"},
+		{Input{File: filePath, Line: 5, Row: 4, Text: "http://"}, "missing host"},
+	}
+	if len(invalid) != len(expected) {
+		t.Fatalf("Expected %d invalid rows, got %v", len(expected), invalid)
+	}
+	for i := range expected {
+		if invalid[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], invalid[i])
+		}
+	}
+}
+
+// Test the priority is read from a priority column of a CSV file and a field of a JSONL file
+func TestReadRows_Priority(t *testing.T) {
+	csvPath, err := createTempCSV("priority,url\n5,www.a.com\n,www.b.com\nhigh,www.c.com\n")
+	if err != nil {
+		t.Fatalf("Failed to create CSV: %v", err)
+	}
+	defer os.Remove(csvPath)
+	jsonlPath := filepath.Join(t.TempDir(), "list.jsonl")
+	content := "{\"url\": \"www.a.com\", \"priority\": 5}\n\n{\"url\": \"www.b.com\"}\n{\"priority\": 1}\nnot json\n"
+	if err := os.WriteFile(jsonlPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create JSONL file: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{csvPath, []string{"www.a.com 5", "www.b.com 0", `line 4: invalid priority "high"`}},
+		{jsonlPath, []string{"www.a.com 5", "www.b.com 0", "line 4: missing url", "line 5: invalid JSON"}},
+	}
+	for _, test := range tests {
+		var rows []string
+		err := readRows(context.Background(), FileSource(test.path), func(row sourceRow) bool {
+			if row.err != nil {
+				rows = append(rows, fmt.Sprintf("line %d: %s", row.Line, row.err.Reason))
+			} else {
+				rows = append(rows, fmt.Sprintf("%s %d", row.url, row.priority))
+			}
+			return true
+		})
+		if err != nil || len(rows) != len(test.expected) {
+			t.Fatalf("%s: expected %d rows, got %v (%v)", test.path, len(test.expected), rows, err)
+		}
+		for i, expected := range test.expected {
+			if !strings.HasPrefix(rows[i], expected) {
+				t.Errorf("%s: expected row %q, got %q", test.path, expected, rows[i])
+			}
+		}
+	}
+}
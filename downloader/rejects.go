@@ -0,0 +1,64 @@
+package downloader
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// RejectLog writes the input rows rejected by Stage 1 to a CSV file with a
+// `file,line,row,text,reason` header, by default `<filePath_without_extension>/rejects.csv`
+// for the command line. It is safe for concurrent use.
+type RejectLog struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *csv.Writer
+}
+
+// OpenRejectLog creates (or truncates) the rejects file.
+func OpenRejectLog(path string) (*RejectLog, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"file", "line", "row", "text", "reason"}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &RejectLog{file: file, w: w}, nil
+}
+
+// Record writes a rejected row, its file, line and row are left empty if unknown. A nil log is a no-op.
+func (r *RejectLog) Record(row RowError) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Write([]string{row.File, itoaOrEmpty(row.Line), itoaOrEmpty(row.Row), row.Text, row.Reason}); err != nil {
+		return err
+	}
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// Close flushes and closes the rejects file.
+func (r *RejectLog) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Flush()
+	return r.file.Close()
+}
+
+// itoaOrEmpty formats n, or returns "" if it is 0.
+func itoaOrEmpty(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
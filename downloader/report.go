@@ -0,0 +1,188 @@
+package downloader
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"time"
+)
+
+// FailedURL is a failed download listed in the run report.
+type FailedURL struct {
+	URL   string `json:"url"`
+	Input *Input `json:"input,omitempty"` // row the URL was read from
+	Class string `json:"failure_class"`
+	Error string `json:"error"`
+}
+
+// Percentiles holds the summary of a histogram in milliseconds.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// Report is the structured summary of a run written by --report.
+type Report struct {
+	Input           string    `json:"input"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Totals          struct {
+		URLs      uint64 `json:"urls"`
+		Successes uint64 `json:"successes"`
+		Failures  uint64 `json:"failures"`
+		Skipped   uint64 `json:"skipped"`
+		Blocked   uint64 `json:"blocked"`
+		Bytes     uint64 `json:"bytes"`
+	} `json:"totals"`
+	FailureRatio   float64           `json:"failure_ratio"`
+	FailureClasses map[string]uint64 `json:"failure_classes"`
+	LatencyMs      Percentiles       `json:"latency_ms"`
+	TTFBMs         Percentiles       `json:"ttfb_ms"`
+	Throughput     struct {
+		MBPerSec   float64 `json:"mb_per_sec"`
+		URLsPerSec float64 `json:"urls_per_sec"`
+	} `json:"throughput"`
+	Hosts      []HostReport  `json:"hosts"`
+	Inputs     []InputReport `json:"inputs,omitempty"` // only for a run reading several inputs
+	FailedURLs []FailedURL   `json:"failed_urls"`
+}
+
+// AddFailedURL remembers a failed URL for the run report.
+func (m *Metrics) AddFailedURL(url string, input Input, class string, err error) {
+	m.failuresMu.Lock()
+	defer m.failuresMu.Unlock()
+	m.failedURLs = append(m.failedURLs, FailedURL{URL: url, Input: input.ref(), Class: class, Error: err.Error()})
+}
+
+// FailureRatio returns the fraction of the URLs read that failed.
+func (m *Metrics) FailureRatio() float64 {
+	total := m.TotalURLs.Load()
+	if total == 0 {
+		return 0
+	}
+	return float64(m.FailureCount.Load()) / float64(total)
+}
+
+// BuildReport returns the report of the run read from input, the first one for a run reading several inputs.
+func (m *Metrics) BuildReport(input string) Report {
+	report := Report{
+		Input:           input,
+		StartTime:       m.PrcStartTime,
+		EndTime:         m.PrcEndTime,
+		DurationSeconds: m.PrcEndTime.Sub(m.PrcStartTime).Seconds(),
+		FailureRatio:    m.FailureRatio(),
+		FailureClasses:  m.FailureCounts(),
+		LatencyMs:       HistogramPercentiles(&m.Latency),
+		TTFBMs:          HistogramPercentiles(&m.TTFB),
+		Hosts:           m.HostReports(),
+	}
+	report.Totals.URLs = m.TotalURLs.Load()
+	report.Totals.Successes = m.SuccessCount.Load()
+	report.Totals.Failures = m.FailureCount.Load()
+	report.Totals.Skipped = m.SkippedCount.Load()
+	report.Totals.Blocked = m.BlockedCount.Load()
+	report.Totals.Bytes = m.TotalBytes.Load()
+	report.Throughput.MBPerSec, report.Throughput.URLsPerSec = m.Throughput()
+	if inputs := m.InputReports(); len(inputs) > 1 {
+		report.Inputs = inputs
+	}
+
+	m.failuresMu.Lock()
+	report.FailedURLs = append([]FailedURL{}, m.failedURLs...)
+	m.failuresMu.Unlock()
+	return report
+}
+
+// HistogramPercentiles summarizes a nanosecond histogram in milliseconds, as in
+// the Report of a run.
+func HistogramPercentiles(h *Histogram) Percentiles {
+	return Percentiles{
+		P50: millis(h.Percentile(50)),
+		P90: millis(h.Percentile(90)),
+		P99: millis(h.Percentile(99)),
+		Max: millis(h.Max()),
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(filePath string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// junitSuite is the JUnit XML layout understood by most CI systems.
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    uint64      `xml:"tests,attr"`
+	Failures uint64      `xml:"failures,attr"`
+	Skipped  uint64      `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite. Every URL processed
+// counts as a test. The failed URLs are listed as test cases with the file and
+// line they were read from; the successful URLs, recorded in the manifest
+// rather than kept in memory, are summed up in a single passing test case, and
+// the skipped and blocked ones in a single skipped test case.
+func (r Report) WriteJUnit(filePath string) error {
+	suite := junitSuite{
+		Name:     fmt.Sprintf("url-downloader %s", r.Input),
+		Tests:    r.Totals.Successes + r.Totals.Failures + r.Totals.Skipped + r.Totals.Blocked,
+		Failures: r.Totals.Failures,
+		Skipped:  r.Totals.Skipped + r.Totals.Blocked,
+		Time:     r.DurationSeconds,
+	}
+	if r.Totals.Successes > 0 {
+		suite.Cases = append(suite.Cases, junitCase{Name: fmt.Sprintf("%d URLs downloaded", r.Totals.Successes), ClassName: "url-downloader"})
+	}
+	if suite.Skipped > 0 {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      fmt.Sprintf("%d URLs not downloaded", suite.Skipped),
+			ClassName: "url-downloader",
+			Skipped:   &junitSkipped{Message: fmt.Sprintf("%d skipped by the size or Content-Type limits, %d blocked by robots.txt", r.Totals.Skipped, r.Totals.Blocked)},
+		})
+	}
+	for _, failed := range r.FailedURLs {
+		testCase := junitCase{
+			Name:      failed.URL,
+			ClassName: hostOf(ensureScheme(failed.URL)),
+			Failure:   &junitFailure{Type: failed.Class, Message: failed.Error},
+		}
+		if failed.Input != nil {
+			testCase.File, testCase.Line = failed.Input.File, failed.Input.Line
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append([]byte(xml.Header), data...), 0644)
+}
@@ -0,0 +1,64 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the JSON report holds totals, failure classes and failed URLs
+func TestReport_WriteJSON(t *testing.T) {
+	m := &Metrics{PrcStartTime: time.Now().Add(-time.Second), PrcEndTime: time.Now()}
+	m.TotalURLs.Add(4)
+	m.AddSuccess(time.Second, time.Millisecond, 10)
+	m.AddFailure("http_404")
+	m.AddFailedURL("www.example.com/missing", Input{File: "input.csv", Line: 3, Row: 2}, "http_404", errors.New("HTTP error: 404"))
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := m.BuildReport("input.csv").WriteJSON(reportPath); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v", err)
+	}
+	if report.Totals.URLs != 4 || report.Totals.Successes != 1 || report.Totals.Failures != 1 {
+		t.Errorf("Unexpected totals: %+v", report.Totals)
+	}
+	if report.FailureRatio != 0.25 {
+		t.Errorf("Expected failure ratio 0.25, got %v", report.FailureRatio)
+	}
+	if report.FailureClasses["http_404"] != 1 || len(report.FailedURLs) != 1 || report.FailedURLs[0].URL != "www.example.com/missing" || report.FailedURLs[0].Input.String() != "input.csv:3" {
+		t.Errorf("Unexpected failures in report: %v %v", report.FailureClasses, report.FailedURLs)
+	}
+}
+
+// Test the JUnit report counts every URL processed and lists failed URLs as failed test cases with their input line
+func TestReport_WriteJUnit(t *testing.T) {
+	m := &Metrics{}
+	m.TotalURLs.Add(4)
+	m.AddSuccess(time.Second, time.Millisecond, 10)
+	m.AddSuccess(time.Second, time.Millisecond, 10)
+	m.AddSkipped()
+	m.AddFailure(FailureTimeout)
+	m.AddFailedURL("https://slow.example.com", Input{File: "input.csv", Line: 7, Row: 6}, FailureTimeout, errors.New("deadline exceeded"))
+
+	reportPath := filepath.Join(t.TempDir(), "report.xml")
+	if err := m.BuildReport("input.csv").WriteJUnit(reportPath); err != nil {
+		t.Fatalf("Failed to write JUnit report: %v", err)
+	}
+	data, _ := os.ReadFile(reportPath)
+	for _, expected := range []string{`tests="4"`, `failures="1"`, `skipped="1"`, `<testcase name="2 URLs downloaded" classname="url-downloader">`, `<testcase name="https://slow.example.com" classname="slow.example.com" file="input.csv" line="7">`, `type="timeout"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %q in JUnit report:\n%s", expected, data)
+		}
+	}
+}
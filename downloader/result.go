@@ -0,0 +1,62 @@
+package downloader
+
+// Status is the overall outcome of a run.
+type Status int
+
+const (
+	StatusSuccess Status = iota
+	StatusPartialFailure
+	StatusTotalFailure
+	StatusInterrupted
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSuccess:
+		return "success"
+	case StatusPartialFailure:
+		return "partial failure"
+	case StatusTotalFailure:
+		return "total failure"
+	case StatusInterrupted:
+		return "interrupted"
+	}
+	return "unknown"
+}
+
+// Result is returned by Run once the pipeline has finished.
+type Result struct {
+	Status    Status
+	Total     uint64 // URLs read from the source
+	Successes uint64
+	Failures  uint64
+	Skipped   uint64   // skipped by the size/Content-Type limits or blocked by robots.txt
+	Metrics   *Metrics // detailed metrics of the run, e.g. for BuildReport
+}
+
+// newResult derives the result of a run from its metrics.
+//
+// Notes:
+// - An interrupted run is reported as such whatever its counts.
+// - Failures within maxFailureRatio count as success.
+// - A run where URLs failed and none succeeded is a total failure.
+func newResult(m *Metrics, interrupted bool, maxFailureRatio float64) Result {
+	result := Result{
+		Total:     m.TotalURLs.Load(),
+		Successes: m.SuccessCount.Load(),
+		Failures:  m.FailureCount.Load(),
+		Skipped:   m.SkippedCount.Load() + m.BlockedCount.Load(),
+		Metrics:   m,
+	}
+	switch {
+	case interrupted:
+		result.Status = StatusInterrupted
+	case result.Failures == 0 || m.FailureRatio() <= maxFailureRatio:
+		result.Status = StatusSuccess
+	case result.Successes == 0:
+		result.Status = StatusTotalFailure
+	default:
+		result.Status = StatusPartialFailure
+	}
+	return result
+}
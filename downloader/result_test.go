@@ -0,0 +1,32 @@
+package downloader
+
+import "testing"
+
+// Test the status derived from the metrics
+func TestNewResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		successes   uint64
+		failures    uint64
+		interrupted bool
+		ratio       float64
+		expected    Status
+	}{
+		{"all succeeded", 4, 0, false, 0, StatusSuccess},
+		{"some failed", 3, 1, false, 0, StatusPartialFailure},
+		{"failures tolerated", 3, 1, false, 0.25, StatusSuccess},
+		{"all failed", 0, 4, false, 0, StatusTotalFailure},
+		{"interrupted", 4, 0, true, 0, StatusInterrupted},
+	}
+	for _, test := range tests {
+		m := &Metrics{}
+		m.TotalURLs.Add(test.successes + test.failures)
+		m.SuccessCount.Add(test.successes)
+		m.FailureCount.Add(test.failures)
+
+		result := newResult(m, test.interrupted, test.ratio)
+		if result.Status != test.expected {
+			t.Errorf("%s: expected status %s, got %s", test.name, test.expected, result.Status)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// RetrySource feeds the failed URLs of a previous run, see LoadFailedURLs.
+// Hooks.OnRetry is called for every one of them. Every URL keeps the input row
+// it was first read from, so that logs, manifest entries and rejected rows of
+// the retry point at the original file and line.
+type RetrySource []FailedURL
+
+// Read sends every URL of the slice to urls.
+func (s RetrySource) Read(ctx context.Context, urls chan<- string) error {
+	return sendRows(ctx, s, urls)
+}
+
+// readLines calls fn for every URL with its original input row; URLs recorded
+// without one, e.g. by a run reading a SliceSource, get their index in s.
+func (s RetrySource) readLines(ctx context.Context, fn func(row sourceRow) bool) error {
+	for i, failed := range s {
+		in := Input{Row: i + 1, Text: failed.URL}
+		if failed.Input != nil {
+			in = *failed.Input
+		}
+		if !fn(sourceRow{Input: in, url: failed.URL}) || ctx.Err() != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// LoadFailedURLs extracts the URLs to retry from a previous run.
+//
+// Input:
+// - filePath: a manifest (`manifest.jsonl`) or a JSON run report written by --report.
+// - classes: failure classes to retry, empty retries every failure.
+//
+// Output:
+// - Returns the failed URLs in the order they were first seen, without duplicates, with the input row they were read from.
+//
+// Notes:
+// - The manifest is appended to by every run, so only the latest outcome of a URL counts.
+// - A URL that failed once and succeeded in a later retry is not retried again.
+func LoadFailedURLs(filePath string, classes []string) ([]FailedURL, error) {
+	var failed []FailedURL
+	var err error
+	if filepath.Ext(filePath) == ".jsonl" {
+		failed, err = failedFromManifest(filePath)
+	} else {
+		failed, err = failedFromReport(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []FailedURL
+	seen := make(map[string]bool)
+	for _, entry := range failed {
+		if seen[entry.URL] || (len(classes) > 0 && !slices.Contains(classes, entry.Class)) {
+			continue
+		}
+		seen[entry.URL] = true
+		urls = append(urls, entry)
+	}
+	return urls, nil
+}
+
+// failedFromManifest returns the URLs whose latest manifest entry is a failure.
+func failedFromManifest(filePath string) ([]FailedURL, error) {
+	entries, err := ReadManifest(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []FailedURL
+	for _, entry := range LatestEntries(entries) {
+		if entry.Outcome == OutcomeFailed {
+			failed = append(failed, FailedURL{URL: entry.URL, Input: entry.Input, Class: entry.FailureClass, Error: entry.Error})
+		}
+	}
+	return failed, nil
+}
+
+// failedFromReport returns the failed URLs listed in a JSON run report.
+func failedFromReport(filePath string) ([]FailedURL, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: invalid report: %v", filePath, err)
+	}
+	return report.FailedURLs, nil
+}
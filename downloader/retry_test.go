@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test only the latest outcome of every URL in the manifest counts
+func TestLoadFailedURLs_Manifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "list")
+	os.MkdirAll(dir, os.ModePerm)
+	manifestFile := filepath.Join(dir, "manifest.jsonl")
+	lines := []string{
+		`{"url":"www.a.com","outcome":"failed","failure_class":"timeout"}`,
+		`{"url":"www.b.com","input":{"file":"list.csv","line":3,"row":2,"text":"www.b.com"},"outcome":"failed","failure_class":"http_404"}`,
+		`{"url":"www.c.com","outcome":"success"}`,
+		`{"url":"www.a.com","outcome":"success"}`,
+		`{"url":"www.d.com","outcome":"failed","failure_class":"timeout"}`,
+	}
+	if err := os.WriteFile(manifestFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	urls, err := LoadFailedURLs(manifestFile, nil)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if len(urls) != 2 || urls[0].URL != "www.b.com" || urls[1].URL != "www.d.com" {
+		t.Errorf("Expected www.b.com,www.d.com, got %v", urls)
+	}
+	if urls[0].Input == nil || urls[0].Input.File != "list.csv" || urls[0].Input.Line != 3 {
+		t.Errorf("Expected www.b.com to keep its input line, got %+v", urls[0].Input)
+	}
+
+	urls, _ = LoadFailedURLs(manifestFile, []string{FailureTimeout})
+	if len(urls) != 1 || urls[0].URL != "www.d.com" {
+		t.Errorf("Expected only the timeout www.d.com, got %v", urls)
+	}
+}
+
+// Test the failed URLs of a JSON report
+func TestLoadFailedURLs_Report(t *testing.T) {
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	content := `{"input":"/data/list.csv","failed_urls":[{"url":"www.a.com","failure_class":"dns"},{"url":"www.b.com","failure_class":"http_500"}]}`
+	if err := os.WriteFile(reportFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	urls, err := LoadFailedURLs(reportFile, []string{"http_500"})
+	if err != nil || len(urls) != 1 || urls[0].URL != "www.b.com" {
+		t.Errorf("Expected [www.b.com], got %v (%v)", urls, err)
+	}
+}
+
+// Test a retried URL is recorded with the file and line it was first read from
+func TestRun_RetryKeepsInput(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	manifestFile := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := OpenManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	original := &Input{File: "list.csv", Line: 5, Row: 4, Text: server.URL + "/missing"}
+	source := RetrySource{{URL: server.URL + "/missing", Input: original}}
+	newTestDownloader(t, Options{Manifest: manifest}).Run(context.Background(), source, &DirSink{Dir: t.TempDir()})
+	manifest.Close()
+
+	entries, err := ReadManifest(manifestFile)
+	if err != nil || len(entries) != 1 || entries[0].Input == nil || *entries[0].Input != *original {
+		t.Errorf("Expected the entry to keep the input %+v, got %+v (%v)", original, entries, err)
+	}
+}
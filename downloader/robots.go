@@ -0,0 +1,412 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ROBOTS_MAX_SIZE = 500 * 1024 // robots.txt bytes parsed per host, as suggested by RFC 9309
+	ROBOTS_MAX_HELD = 1000       // URLs held back while their host is not due, see pace
+
+	DEFAULT_ROBOTS_AGENT = "go-http-client" // product token of the User-Agent sent by net/http
+)
+
+// robotsRule is a single Allow or Disallow line.
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsPolicy holds the rules of the robots.txt group that applies to our User-Agent.
+type robotsPolicy struct {
+	rules      []robotsRule
+	disallowed bool          // robots.txt was unreachable, everything is disallowed
+	crawlDelay time.Duration // minimum delay between requests to the host
+}
+
+// robotsHost caches the policy of one host and paces the requests to it.
+type robotsHost struct {
+	ready  chan struct{} // closed once policy is fetched
+	policy robotsPolicy
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next request honouring Crawl-delay
+}
+
+// robotsCache fetches robots.txt once per scheme and host and shares it between workers.
+type robotsCache struct {
+	fetch func(ctx context.Context, origin string) robotsPolicy
+
+	mu    sync.Mutex
+	hosts map[string]*robotsHost
+}
+
+func newRobotsCache(fetch func(ctx context.Context, origin string) robotsPolicy) *robotsCache {
+	return &robotsCache{fetch: fetch, hosts: make(map[string]*robotsHost)}
+}
+
+// permits reports whether robots.txt allows the URL. The returned host is nil
+// if there is no policy to apply. A nil cache allows everything.
+//
+// Notes:
+// - The first caller to see a host fetches its robots.txt, the others wait for it.
+// - Crawl-delay is not waited for, see pace.
+// - Returns ctx.Err() if the context is canceled while waiting.
+func (c *robotsCache) permits(ctx context.Context, rawURL string) (*robotsHost, bool, error) {
+	if c == nil {
+		return nil, true, nil
+	}
+	host, origin, path, created := c.lookup(rawURL)
+	if host == nil {
+		return nil, true, nil // let the download report the invalid URL
+	}
+	if created {
+		c.load(ctx, origin, host)
+	}
+	select {
+	case <-host.ready:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	return host, host.policy.allows(path), nil
+}
+
+// lookup returns the host of rawURL, its origin and the path robots.txt rules
+// are matched against. created is true if the caller must load the host.
+// The host is nil for an invalid URL.
+func (c *robotsCache) lookup(rawURL string) (host *robotsHost, origin string, path string, created bool) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", "", false
+	}
+	origin = target.Scheme + "://" + target.Host
+	path = target.EscapedPath()
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	host, ok := c.hosts[origin]
+	if !ok {
+		host = &robotsHost{ready: make(chan struct{})}
+		c.hosts[origin] = host
+	}
+	return host, origin, path, !ok
+}
+
+// load fetches the robots.txt of a host created by lookup.
+func (c *robotsCache) load(ctx context.Context, origin string, host *robotsHost) {
+	host.policy = c.fetch(ctx, origin)
+	close(host.ready)
+}
+
+// due returns when a request to path may start. ok is false while the
+// robots.txt of the host is being fetched.
+func (h *robotsHost) due(path string) (at time.Time, ok bool) {
+	select {
+	case <-h.ready:
+	default:
+		return time.Time{}, false
+	}
+	if h.policy.crawlDelay <= 0 || !h.policy.allows(path) {
+		return time.Time{}, true // disallowed URLs are not requested
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.next, true
+}
+
+// reserve starts the Crawl-delay of the host for a request to path starting now.
+func (h *robotsHost) reserve(path string) {
+	if h.policy.crawlDelay <= 0 || !h.policy.allows(path) {
+		return
+	}
+	h.mu.Lock()
+	h.next = time.Now().Add(h.policy.crawlDelay)
+	h.mu.Unlock()
+}
+
+// held is a job waiting in pace for its host to be due.
+type held struct {
+	job
+	host *robotsHost // nil for an invalid URL, due at once
+	path string
+}
+
+// pace moves jobs to Stage 2 once their host is due, so that a URL waiting for
+// the Crawl-delay of its host does not hold a worker.
+//
+// Input:
+// - ctx: Context for graceful shutdown.
+// - in: The jobs read by Stage 1, or dispatched by schedule.
+// - out: The jobs dispatched to Stage 2, closed once in is drained or ctx is done.
+// - pending: Updated with the number of jobs held back.
+//
+// Notes:
+// - The robots.txt of a new host is fetched in the background; the jobs of the host are held until it is parsed.
+// - A job is due once its host's Crawl-delay since the previous request has elapsed; disallowed URLs are due at once and recorded as blocked by Stage 2.
+// - The first held job that is due goes first, so the jobs of other hosts overtake the ones waiting for a Crawl-delay.
+// - Up to ROBOTS_MAX_HELD jobs are held; once full, pace stops reading until one is due.
+// - out is unbuffered and Stage 2 reads it once a worker is free, so the Crawl-delay is counted from the start of the request.
+func (d *Downloader) pace(ctx context.Context, in <-chan job, out chan<- job, pending *atomic.Int64) {
+	defer close(out)
+	var loads sync.WaitGroup
+	defer loads.Wait()               // no robots.txt fetch outlives the run
+	loaded := make(chan struct{}, 1) // a robots.txt fetched in the background was parsed
+
+	var buffer []held
+	hold := func(item job) {
+		host, origin, path, created := d.robots.lookup(ensureScheme(item.url))
+		if created {
+			loads.Add(1)
+			go func() {
+				defer loads.Done()
+				d.robots.load(ctx, origin, host)
+				select {
+				case loaded <- struct{}{}:
+				default:
+				}
+			}()
+		}
+		buffer = append(buffer, held{job: item, host: host, path: path})
+	}
+
+	for in != nil || len(buffer) > 0 {
+		// Find the first held job that is due, or when the next one will be
+		now := time.Now()
+		due, wake := -1, time.Time{}
+		for i, item := range buffer {
+			if item.host == nil {
+				due = i
+				break
+			}
+			at, ok := item.host.due(item.path)
+			if !ok {
+				continue // woken up by loaded
+			}
+			if !at.After(now) {
+				due = i
+				break
+			}
+			if wake.IsZero() || at.Before(wake) {
+				wake = at
+			}
+		}
+
+		var receive <-chan job
+		if len(buffer) < ROBOTS_MAX_HELD {
+			receive = in // nil once Stage 1 is done, or while the buffer is full
+		}
+		var dispatch chan<- job
+		var next job
+		if due >= 0 {
+			dispatch, next = out, buffer[due].job
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if due < 0 && !wake.IsZero() {
+			timer = time.NewTimer(wake.Sub(now))
+			timeout = timer.C
+		}
+
+		select {
+		case item, ok := <-receive:
+			if !ok {
+				in = nil
+				break
+			}
+			hold(item)
+		case dispatch <- next:
+			if host := buffer[due].host; host != nil {
+				host.reserve(buffer[due].path)
+			}
+			buffer = slices.Delete(buffer, due, due+1)
+		case <-loaded:
+		case <-timeout:
+		case <-ctx.Done():
+			d.log.Info().Msgf("Robots: Context canceled / Shutdown initiated. Dropping %d held URLs.", len(buffer))
+			pending.Store(0)
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		pending.Store(int64(len(buffer)))
+	}
+}
+
+// newRobotsClient returns a copy of client that fetches robots.txt. It follows
+// redirects whatever the download policy of Options.Redirects, as RFC 9309
+// requires, and refuses the private addresses with rejectPrivate.
+func newRobotsClient(client *http.Client, rejectPrivate bool) *http.Client {
+	robots := *client
+	robots.CheckRedirect = nil // the default policy, up to 10 redirects
+	if rejectPrivate {
+		return rejectPrivateRedirects(&robots)
+	}
+	return &robots
+}
+
+// fetchRobots downloads and parses `<origin>/robots.txt` with the robots.txt
+// client, see newRobotsClient.
+//
+// Notes:
+// - 4xx responses, and 3xx responses left once the redirects are followed, mean there are no restrictions.
+// - 5xx responses and network errors mean the host is unreachable and everything is disallowed.
+func (d *Downloader) fetchRobots(ctx context.Context, origin string) robotsPolicy {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return robotsPolicy{}
+	}
+	d.applyRequestOptions(req)
+	resp, err := d.robotsClient.Do(req)
+	if err != nil {
+		d.log.Warn().Msgf("Error fetching robots.txt for %s: %v", origin, err)
+		return robotsPolicy{disallowed: true}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		d.log.Warn().Msgf("robots.txt for %s is unreachable: HTTP %d", origin, resp.StatusCode)
+		return robotsPolicy{disallowed: true}
+	case resp.StatusCode >= 300:
+		return robotsPolicy{}
+	}
+	return parseRobots(io.LimitReader(resp.Body, ROBOTS_MAX_SIZE), d.opts.UserAgent)
+}
+
+// parseRobots returns the group matching the product token of agent, falling
+// back to the `*` group.
+//
+// Notes:
+// - A group matches if its user-agent is the product token, compared case-insensitively as RFC 9309 requires.
+// - An empty agent is the User-Agent sent by net/http, whose product token is DEFAULT_ROBOTS_AGENT.
+func parseRobots(r io.Reader, agent string) robotsPolicy {
+	token := productToken(agent)
+
+	var specific, wildcard *robotsPolicy
+	var current []*robotsPolicy // groups the following rules belong to
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+			name := strings.ToLower(value)
+			switch {
+			case name == "*":
+				if wildcard == nil {
+					wildcard = &robotsPolicy{}
+				}
+				current = append(current, wildcard)
+			case name == token:
+				if specific == nil {
+					specific = &robotsPolicy{}
+				}
+				current = append(current, specific)
+			}
+			continue
+		}
+		inAgents = false
+
+		for _, group := range current {
+			switch key {
+			case "allow", "disallow":
+				if value != "" {
+					group.rules = append(group.rules, robotsRule{pattern: value, allow: key == "allow"})
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	switch {
+	case specific != nil:
+		return *specific
+	case wildcard != nil:
+		return *wildcard
+	}
+	return robotsPolicy{}
+}
+
+// productToken returns the product token of a User-Agent, the name before its
+// version, lowercased, e.g. "mybot" for "MyBot/1.0 (+https://example.com)".
+func productToken(agent string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(agent), " ")
+	name, _, _ = strings.Cut(name, "/")
+	if name == "" {
+		return DEFAULT_ROBOTS_AGENT
+	}
+	return strings.ToLower(name)
+}
+
+// allows applies the longest matching rule; Allow wins ties.
+func (p robotsPolicy) allows(path string) bool {
+	if p.disallowed {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	allowed, longest := true, -1
+	for _, rule := range p.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a path against a robots.txt pattern supporting `*` and a trailing `$`.
+func robotsMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	if anchored && rest != "" {
+		// The last literal must end the path; retry it against the suffix
+		last := parts[len(parts)-1]
+		return len(parts) > 1 && strings.HasSuffix(path, last)
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+
+User-agent: url-downloader
+Disallow: /downloads-only
+Crawl-delay: 1.5
+`
+
+// Test the group matching our User-Agent is selected
+func TestParseRobots_SpecificAgent(t *testing.T) {
+	policy := parseRobots(strings.NewReader(testRobots), "url-downloader/1.0.0")
+	if policy.allows("/downloads-only/file") {
+		t.Errorf("Expected /downloads-only to be disallowed")
+	}
+	if !policy.allows("/private") {
+		t.Errorf("Expected /private to be allowed for the specific group")
+	}
+	if policy.crawlDelay != 1500*time.Millisecond {
+		t.Errorf("Expected Crawl-delay 1.5s, got %v", policy.crawlDelay)
+	}
+}
+
+// Test the wildcard group with longest match and wildcards
+func TestParseRobots_Wildcard(t *testing.T) {
+	policy := parseRobots(strings.NewReader(testRobots), "other-bot/2.0")
+	tests := map[string]bool{
+		"/":                    true,
+		"/private/data":        false,
+		"/private/public/page": true,
+		"/docs/report.pdf":     false,
+		"/docs/report.pdf?x=1": true,
+	}
+	for path, expected := range tests {
+		if got := policy.allows(path); got != expected {
+			t.Errorf("allows(%q) = %v, expected %v", path, got, expected)
+		}
+	}
+}
+
+// Test a group only matches the product token of the User-Agent, case-insensitively, and an empty User-Agent is the net/http one
+func TestParseRobots_ProductToken(t *testing.T) {
+	robots := testRobots + "\nUser-agent: go-http-client\nDisallow: /go\n\nUser-agent: bot\nDisallow: /\n"
+	tests := []struct {
+		agent   string
+		path    string
+		allowed bool
+	}{
+		{"URL-Downloader/2.0 (+https://example.com)", "/downloads-only", false},
+		{"url-downloader-ci/1.0", "/downloads-only", true}, // contains the token of another group
+		{"mybot/1.0", "/anything", true},                   // "bot" is not its product token
+		{"", "/go", false},
+		{"", "/private", true},
+	}
+	for _, test := range tests {
+		policy := parseRobots(strings.NewReader(robots), test.agent)
+		if got := policy.allows(test.path); got != test.allowed {
+			t.Errorf("%q: allows(%q) = %v, expected %v", test.agent, test.path, got, test.allowed)
+		}
+	}
+}
+
+// Test robots.txt redirects are followed even when the downloads don't follow them
+func TestFetchRobots_FollowsRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.Redirect(w, r, "/moved/robots.txt", http.StatusMovedPermanently)
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /blocked\n"))
+	}))
+	defer server.Close()
+
+	d := newTestDownloader(t, Options{RespectRobots: true, Redirects: RedirectOptions{NoFollow: true}})
+	if policy := d.fetchRobots(context.Background(), server.URL); policy.allows("/blocked") {
+		t.Errorf("Expected the redirected robots.txt to disallow /blocked, got %+v", policy)
+	}
+}
+
+// Test robots.txt is fetched once per host and unreachable hosts are blocked
+func TestRobotsCache_Allowed(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write([]byte("User-agent: *\nDisallow: /blocked\n"))
+	}))
+	defer server.Close()
+
+	cache := newRobotsCache(newTestDownloader(t, Options{RespectRobots: true}).fetchRobots)
+	ctx := context.Background()
+	if _, allowed, _ := cache.permits(ctx, server.URL+"/blocked/page"); allowed {
+		t.Errorf("Expected /blocked/page to be disallowed")
+	}
+	if _, allowed, _ := cache.permits(ctx, server.URL+"/open"); !allowed {
+		t.Errorf("Expected /open to be allowed")
+	}
+	if fetches != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", fetches)
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	if _, allowed, _ := cache.permits(ctx, down.URL+"/open"); allowed {
+		t.Errorf("Expected everything to be disallowed when robots.txt is unreachable")
+	}
+}
+
+// Test a host waiting for its Crawl-delay does not hold the workers of other hosts
+func TestRun_CrawlDelay(t *testing.T) {
+	var slowHits, fastHits atomic.Int64
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nCrawl-delay: 10\n"))
+			return
+		}
+		slowHits.Add(1)
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fastHits.Add(1)
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	var urls SliceSource
+	for i := 0; i < 5; i++ {
+		urls = append(urls, fmt.Sprintf("%s/slow/%d", slow.URL, i))
+	}
+	for i := 0; i < 5; i++ {
+		urls = append(urls, fmt.Sprintf("%s/fast/%d", fast.URL, i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	d := newTestDownloader(t, Options{Workers: 2, RespectRobots: true})
+	start := time.Now()
+	d.Run(ctx, urls, &DirSink{Dir: t.TempDir()})
+
+	if slowHits.Load() != 1 {
+		t.Errorf("Expected a single request to the slow host within its Crawl-delay, got %d", slowHits.Load())
+	}
+	if fastHits.Load() != 5 {
+		t.Errorf("Expected the 5 URLs of the other host to be downloaded, got %d", fastHits.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected Run to stop with the context, took %v", elapsed)
+	}
+}
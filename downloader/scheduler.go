@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"container/heap"
+	"context"
+	"sync/atomic"
+)
+
+// scheduled is a job waiting in the look-ahead buffer of schedule.
+type scheduled struct {
+	job
+	seq uint64 // order of arrival, the first read goes first among equal priorities
+}
+
+// jobHeap is a max-heap of jobs by priority, then by order of arrival.
+type jobHeap []scheduled
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x any) { *h = append(*h, x.(scheduled)) }
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// schedule moves the jobs read by Stage 1 to Stage 2, highest priority first.
+//
+// Input:
+// - ctx: Context for graceful shutdown.
+// - in: The jobs read by Stage 1, in input order.
+// - out: The jobs dispatched to Stage 2, closed once in is drained or ctx is done.
+// - pending: Updated with the number of jobs in the look-ahead buffer.
+//
+// Notes:
+// - Up to Options.LookAhead jobs are buffered; a higher priority read further ahead is only seen once the buffer has room.
+// - The buffer is refilled from in before every dispatch, so that a fast reader keeps it full.
+// - Jobs of equal priority are dispatched in input order.
+func (d *Downloader) schedule(ctx context.Context, in <-chan job, out chan<- job, pending *atomic.Int64) {
+	defer close(out)
+	var buffer jobHeap
+	var seq uint64
+	push := func(item job) {
+		seq++
+		heap.Push(&buffer, scheduled{job: item, seq: seq})
+		pending.Store(int64(buffer.Len()))
+	}
+
+	for in != nil || buffer.Len() > 0 {
+		// Fill the buffer with what Stage 1 already read
+		if in != nil && buffer.Len() < d.opts.LookAhead {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					in = nil
+				} else {
+					push(item)
+				}
+				continue
+			default:
+			}
+		}
+
+		var receive <-chan job
+		if buffer.Len() < d.opts.LookAhead {
+			receive = in // nil once Stage 1 is done, or while the buffer is full
+		}
+		var dispatch chan<- job
+		var next job
+		if buffer.Len() > 0 {
+			dispatch, next = out, buffer[0].job
+		}
+
+		select {
+		case item, ok := <-receive:
+			if !ok {
+				in = nil
+				continue
+			}
+			push(item)
+		case dispatch <- next:
+			heap.Pop(&buffer)
+			pending.Store(int64(buffer.Len()))
+		case <-ctx.Done():
+			d.log.Info().Msgf("Scheduler: Context canceled / Shutdown initiated. Dropping %d scheduled URLs.", buffer.Len())
+			pending.Store(0)
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package downloader
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Test jobs are dispatched by priority within the look-ahead buffer, in input order among equals
+func TestSchedule(t *testing.T) {
+	jobs := []job{{url: "a", priority: 1}, {url: "b", priority: 5}, {url: "c", priority: 3}, {url: "d", priority: 9}, {url: "e", priority: 5}}
+	tests := []struct {
+		lookAhead int
+		expected  string
+	}{
+		{10, "d b e c a"},
+		{2, "b c d e a"},
+		{1, "a b c d e"},
+	}
+	for _, test := range tests {
+		in := make(chan job, len(jobs))
+		for _, item := range jobs {
+			in <- item
+		}
+		close(in)
+
+		d := newTestDownloader(t, Options{LookAhead: test.lookAhead})
+		out := make(chan job)
+		var pending atomic.Int64
+		go d.schedule(context.Background(), in, out, &pending)
+		var order []string
+		for item := range out {
+			order = append(order, item.url)
+		}
+		if got := strings.Join(order, " "); got != test.expected {
+			t.Errorf("LookAhead %d: expected %q, got %q", test.lookAhead, test.expected, got)
+		}
+		if pending.Load() != 0 {
+			t.Errorf("LookAhead %d: expected an empty buffer, got %d", test.lookAhead, pending.Load())
+		}
+	}
+}
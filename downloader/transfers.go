@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Transfer is a snapshot of a download whose body is being read.
+type Transfer struct {
+	URL     string
+	Total   int64 // Content-Length, -1 if unknown
+	Read    int64 // bytes read so far
+	Started time.Time
+}
+
+// transfer is a download whose body is being read.
+type transfer struct {
+	url     string
+	total   int64
+	read    atomic.Int64
+	started time.Time
+}
+
+// progressReader counts the bytes read from a response body.
+type progressReader struct {
+	reader   io.Reader
+	transfer *transfer
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.transfer.read.Add(int64(n))
+	return n, err
+}
+
+// transferSet holds the in-flight transfers of a run.
+type transferSet struct {
+	mu    sync.Mutex
+	items map[*transfer]struct{}
+}
+
+// trackTransfer registers a transfer and wraps body to count its bytes. The
+// returned function removes the transfer once the body is read.
+func (m *Metrics) trackTransfer(url string, total int64, body io.Reader) (io.Reader, func()) {
+	s := &m.transfers
+	t := &transfer{url: url, total: total, started: time.Now()}
+	s.mu.Lock()
+	if s.items == nil {
+		s.items = make(map[*transfer]struct{})
+	}
+	s.items[t] = struct{}{}
+	s.mu.Unlock()
+	return &progressReader{reader: body, transfer: t}, func() {
+		s.mu.Lock()
+		delete(s.items, t)
+		s.mu.Unlock()
+	}
+}
+
+// Transfers returns the downloads whose body is being read, oldest first.
+func (m *Metrics) Transfers() []Transfer {
+	s := &m.transfers
+	s.mu.Lock()
+	items := make([]Transfer, 0, len(s.items))
+	for t := range s.items {
+		items = append(items, Transfer{URL: t.url, Total: t.total, Read: t.read.Load(), Started: t.started})
+	}
+	s.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool { return items[i].Started.Before(items[j].Started) })
+	return items
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// Verification statuses of a saved file.
+const (
+	VerifyOK         = "ok"
+	VerifyMismatch   = FailureChecksum // the file no longer matches the recorded SHA-256
+	VerifyMissing    = "missing"
+	VerifyNoChecksum = "no_checksum" // recorded before checksums were added to the manifest
+)
+
+// VerifiedFile is the verification status of the file saved for a URL.
+type VerifiedFile struct {
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// VerifyManifest re-hashes the files saved by the latest successful entry of
+// every URL of a manifest and compares them with the recorded SHA-256.
+//
+// Input:
+// - filePath: manifest written by a run.
+//
+// Output:
+// - One VerifiedFile per successfully downloaded URL, in manifest order.
+// - Returns an error if the manifest cannot be read.
+//
+// Notes:
+// - Failed and skipped URLs have no file and are not listed.
+// - DirSink records absolute paths; a relative path, e.g. written by an older run, is resolved against the working directory.
+func VerifyManifest(filePath string) ([]VerifiedFile, error) {
+	entries, err := ReadManifest(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []VerifiedFile
+	for _, entry := range LatestEntries(entries) {
+		if entry.Outcome != OutcomeSuccess {
+			continue
+		}
+		file := VerifiedFile{URL: entry.URL, Path: entry.Path, Status: VerifyOK}
+		if entry.SHA256 == "" {
+			file.Status = VerifyNoChecksum
+			files = append(files, file)
+			continue
+		}
+		sum, err := hashFile(entry.Path)
+		switch {
+		case os.IsNotExist(err):
+			file.Status = VerifyMissing
+		case err != nil:
+			file.Status, file.Error = VerifyMissing, err.Error()
+		case sum != entry.SHA256:
+			file.Status = VerifyMismatch
+			file.Error = "expected sha256 " + entry.SHA256 + ", got " + sum
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// hashFile returns the hex SHA-256 of a file.
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
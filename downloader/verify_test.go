@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test saved files are checked against the SHA-256 recorded when they were persisted
+func TestVerifyManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifestFile := filepath.Join(dir, "manifest.jsonl")
+	manifest, err := OpenManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	sink, _ := NewDirSink(filepath.Join(dir, "downloads"))
+
+	downloads := make(chan Download, 3)
+	downloads <- Download{URL: "www.a.com", Content: []byte("kept")}
+	downloads <- Download{URL: "www.b.com", Content: []byte("changed")}
+	downloads <- Download{URL: "www.c.com", Content: []byte("removed")}
+	close(downloads)
+	newTestDownloader(t, Options{Manifest: manifest}).persistContent(context.Background(), downloads, sink, &Metrics{})
+	manifest.Close()
+
+	files, err := VerifyManifest(manifestFile)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %v (%v)", files, err)
+	}
+	if files[0].Status != VerifyOK {
+		t.Errorf("Expected %s to verify, got %+v", files[0].Path, files[0])
+	}
+
+	os.WriteFile(files[1].Path, []byte("tampered"), 0644)
+	os.Remove(files[2].Path)
+	files, _ = VerifyManifest(manifestFile)
+	if files[1].Status != VerifyMismatch || files[2].Status != VerifyMissing {
+		t.Errorf("Expected a mismatch and a missing file, got %+v", files)
+	}
+}
+
+// Test a manifest written with a relative output directory verifies from another directory
+func TestVerifyManifest_RelativeDir(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+
+	manifestFile := filepath.Join(dir, "manifest.jsonl")
+	manifest, err := OpenManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	downloads := make(chan Download, 1)
+	downloads <- Download{URL: "www.a.com", Content: []byte("kept")}
+	close(downloads)
+	newTestDownloader(t, Options{Manifest: manifest}).persistContent(context.Background(), downloads, &DirSink{Dir: "."}, &Metrics{})
+	manifest.Close()
+
+	os.Chdir(wd)
+	files, err := VerifyManifest(manifestFile)
+	if err != nil || len(files) != 1 || files[0].Status != VerifyOK || !filepath.IsAbs(files[0].Path) {
+		t.Errorf("Expected the file to verify by its absolute path, got %+v (%v)", files, err)
+	}
+}
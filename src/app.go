@@ -2,82 +2,223 @@ package src
 
 import (
 	"context"
-	"github.com/rs/zerolog"
-	"sync"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
-)
-
-var (
-	zlog        zerolog.Logger
-	wg          sync.WaitGroup // main wait group
-	urlChan     = make(chan string, MAX_WORKERS)
-	contentChan = make(chan downloadResult, MAX_WORKERS)
-	metrics     *Metrics
 
+	"github.com/garunkumar450/url-downloader/downloader"
+	"github.com/rs/zerolog"
 )
 
-func Start() error {
+var zlog zerolog.Logger
+
+// Start runs the downloader pipeline on csvFilePath until every URL is processed,
+// the shutdown deadline is reached or SIGINT/SIGTERM is received.
+//
+// Output:
+// - Returns the Result of the run; ExitCode tells schedulers how the run went.
+// - Returns an error if the run could not start.
+//
+// Notes:
+// - The downloads, manifest, rejected rows, log and host report go to the directory named after the csv file.
+// - With several input files, each one gets its own downloads, manifest and rejected rows; the log and host report go to the directory of the first one.
+func Start() (downloader.Result, error) {
 	var err error
 
-	err, zlog = initLogger(csvFilePath)
+	mode := resolveProgressMode(progressMode)
+	level, _ := zerolog.ParseLevel(logLevel) // validated by postValidator
+	err, zlog = initLogger(csvFilePath, mode != ProgressTTY, level)
 	if err != nil {
-		return err
+		return downloader.Result{}, err
 	}
-	// Create a context with a 5-second timeout for graceful shutdowt
-	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_DEAD_LINE)
+
+	// Create a context with a --timeout deadline (5 seconds by default) for graceful shutdowt, canceled early on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
 	defer cancel()
-	metrics = &Metrics{}
+	return runInputs(ctx, mode)
+}
+
+// runInputs runs the downloader pipeline on the input files of the run until
+// every URL is processed or ctx is done, with the progress display mode.
+//
+// Output:
+// - Returns the Result of the run, and an error if the run could not start.
+// - Writes the manifest, rejected rows, downloads, host report and requested reports, see Start.
+func runInputs(ctx context.Context, mode string) (downloader.Result, error) {
+	metrics := &downloader.Metrics{}
+	outputs, err := openOutputs()
+	defer outputs.Close()
+	if err != nil {
+		return downloader.Result{}, err
+	}
+	sink := downloader.InputSinks{Sinks: outputs.sinks, Default: outputs.sinks[csvFilePath]}
+
+	options.Logger = zlog
+	options.Metrics = metrics
+	// The URLs of a retry keep their input file; if it is not an input of this run,
+	// e.g. named by another path, they go to the outputs of the run it retries
+	options.Manifest = outputs.manifests[csvFilePath]
+	options.Rejects = outputs.rejects[csvFilePath]
+	options.InputManifests = outputs.manifests
+	options.InputRejects = outputs.rejects
+	if onPersisted != "" {
+		options.Hooks = &commandHook{command: onPersisted}
+	}
+	d, err := downloader.New(options)
+	if err != nil {
+		return downloader.Result{}, err
+	}
+
+	if metricsAddr != "" {
+		stopMetricsServer, err := startMetricsServer(metricsAddr, metrics)
+		if err != nil {
+			return downloader.Result{}, err
+		}
+		defer stopMetricsServer()
+	}
+
+	source, err := newSource()
+	if err != nil {
+		return downloader.Result{}, err
+	}
+	if retryURLs, ok := source.(downloader.RetrySource); ok {
+		metrics.ExpectedURLs.Store(uint64(len(retryURLs)))
+	} else if mode != ProgressOff {
+		// Progress display, the row count gives the ETA
+		for _, input := range inputFiles {
+			rows, err := downloader.CountCSVRows(input)
+			if err != nil {
+				return downloader.Result{}, err
+			}
+			metrics.ExpectedURLs.Add(rows)
+		}
+	}
 	metrics.PrcStartTime = time.Now()
+	stopProgress := startProgress(metrics, mode, progressInterval, os.Stdout)
+
+	// This is a blocking call
+	result, err := d.Run(ctx, source, sink)
+	stopProgress()
+	if err != nil {
+		return result, err
+	}
 
-	// Stage 1: Read file
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer close(urlChan)
-		zlog.Info().Msg("Stage-1 Started Reading Csv file")
-		readCSVFile(csvFilePath, urlChan, metrics, ctx)
-		zlog.Info().Msg("Stage-1 Completed ")
-	}()
-
-	// Stage 2: Download URLs
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		zlog.Info().Msg("Stage-2 Started  download URLS")
-		downloadURLs(urlChan, contentChan, metrics, ctx, &wg)
-		zlog.Info().Msg("Stage-2 Completed ")
-	}()
-
-	// Close contentChan once all download goroutines are done
-	go func() {
-		wg.Wait()
-		close(contentChan)
-	}()
-
-
-
-
-	// Stage 3: Persist Contents (Single Goroutine)
-	var persistWg sync.WaitGroup
-	persistWg.Add(1)
-	go func() {
-		zlog.Info().Msg("Stage-3 Started  Persistent")
-		defer persistWg.Done()
-		persistContent(contentChan, csvFilePath, ctx)
-		zlog.Info().Msg("Stage-3 Completed ")
-	}()
-	persistWg.Wait()
-
-	metrics.PrcEndTime = time.Now()
-	metrics.LogSummary()
+	metrics.LogSummary(zlog)
+	if err := metrics.WriteHostReport(hostReportPath(csvFilePath)); err != nil {
+		zlog.Error().Msgf("Error writing host report: %v", err)
+	}
+	writeReports(metrics.BuildReport(csvFilePath))
 
 	// Graceful shutdown
-	select {
-	case <-ctx.Done():
-		zlog.Info().Msg("Shutdown deadline reached. Exiting...")
-	default:
+	if result.Status == downloader.StatusInterrupted {
+		zlog.Info().Msgf("Shutdown deadline reached or interrupted (%v). Exiting...", context.Cause(ctx))
+	} else {
 		zlog.Info().Msg("All tasks completed. Exiting...")
 	}
+	zlog.Info().Str("Status", result.Status.String()).Int("Exit Code", ExitCode(result)).Msg("Run finished")
+	return result, nil
+}
+
+// writeReports writes the JSON and JUnit run reports requested on the command line.
+func writeReports(report downloader.Report) {
+	if reportFile != "" {
+		if err := report.WriteJSON(reportFile); err != nil {
+			zlog.Error().Msgf("Error writing report: %v", err)
+		}
+	}
+	if junitReportFile != "" {
+		if err := report.WriteJUnit(junitReportFile); err != nil {
+			zlog.Error().Msgf("Error writing JUnit report: %v", err)
+		}
+	}
+}
+
+// outputs holds the manifest, rejected rows and downloads of every input file of a run.
+type outputs struct {
+	manifests map[string]*downloader.Manifest
+	rejects   map[string]*downloader.RejectLog
+	sinks     map[string]downloader.Sink
+}
+
+// openOutputs creates the output directory of every input file and opens its
+// manifest, rejected rows and downloads. The returned outputs must be closed,
+// even on error.
+func openOutputs() (*outputs, error) {
+	outputs := &outputs{
+		manifests: make(map[string]*downloader.Manifest),
+		rejects:   make(map[string]*downloader.RejectLog),
+		sinks:     make(map[string]downloader.Sink),
+	}
+	for _, input := range inputFiles {
+		if err := os.MkdirAll(getOutputBase(input), os.ModePerm); err != nil {
+			return outputs, fmt.Errorf("creating output directory: %v", err)
+		}
+		manifest, err := downloader.OpenManifest(manifestPath(input))
+		if err != nil {
+			return outputs, err
+		}
+		outputs.manifests[input] = manifest
+		// A retry reads URLs already accepted by the run it retries, keep the rejects of that run
+		if retryFile == "" {
+			rejects, err := downloader.OpenRejectLog(rejectsPath(input))
+			if err != nil {
+				return outputs, err
+			}
+			outputs.rejects[input] = rejects
+		}
+		if outputs.sinks[input], err = downloader.NewDirSink(downloadDir(input)); err != nil {
+			return outputs, err
+		}
+	}
+	return outputs, nil
+}
 
-	return nil
+// Close closes the manifests and rejected rows files.
+func (o *outputs) Close() {
+	for _, manifest := range o.manifests {
+		manifest.Close()
+	}
+	for _, rejects := range o.rejects {
+		rejects.Close()
+	}
+}
+
+// newSource returns the source of the run: the failed URLs of the run to retry
+// or the input files, read one after the other.
+func newSource() (downloader.Source, error) {
+	if retryFile == "" {
+		if len(inputFiles) == 1 {
+			return downloader.FileSource(csvFilePath), nil
+		}
+		sources := make(downloader.MultiSource, 0, len(inputFiles))
+		for _, input := range inputFiles {
+			sources = append(sources, downloader.FileSource(input))
+		}
+		return sources, nil
+	}
+	// A retry feeds the failed URLs of a previous run to Stage 2 instead of the csv file
+	retryURLs, err := downloader.LoadFailedURLs(retryFile, retryClasses)
+	if err != nil {
+		return nil, err
+	}
+	zlog.Info().Msgf("Retrying %d failed URLs from %s", len(retryURLs), retryFile)
+	return downloader.RetrySource(retryURLs), nil
+}
+
+// downloadDir returns the directory of the files downloaded from input, --output-dir
+// or <file_without_extension>/downloads. With several input files, --output-dir
+// gets a subdirectory per input, named after the file without its extension.
+func downloadDir(input string) string {
+	if outputDir != "" && len(inputFiles) > 1 {
+		return filepath.Join(outputDir, filepath.Base(getOutputBase(input)))
+	}
+	if outputDir != "" {
+		return outputDir
+	}
+	return filepath.Join(getOutputBase(input), "downloads")
 }
@@ -0,0 +1,246 @@
+package src
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// command is a subcommand of url-downloader, e.g. `url-downloader verify`.
+type command struct {
+	name    string
+	args    string // positional arguments shown in the usage line
+	summary string
+
+	// flags registers the flags of the command and returns the function
+	// completing its configuration once they are parsed.
+	flags func(fs *flag.FlagSet) func(args []string) error
+
+	// run executes the configured command and returns the process exit code.
+	run func() int
+}
+
+var commands = []*command{
+	{name: "download", args: "[<file.csv>...]", summary: "Download the URLs of a CSV or JSONL file (the default command)", flags: downloadFlags, run: runDownload},
+	{name: "validate", args: "<file.csv>", summary: "Report the rows of a CSV or JSONL file that cannot be downloaded, without downloading", flags: validateFlags, run: runValidate},
+	{name: "report", args: "<manifest.jsonl>", summary: "Summarize the manifest of a previous run", flags: reportFlags, run: runReport},
+	{name: "retry", args: "<manifest.jsonl|report.json>", summary: "Download the failed URLs of a previous run again", flags: retryFlags, run: runDownload},
+	{name: "verify", args: "<manifest.jsonl>", summary: "Re-hash the saved files and compare them with the manifest", flags: verifyFlags, run: runVerify},
+	{name: "watch", args: "<dir>", summary: "Download the CSV and JSONL files dropped into a directory as they appear", flags: watchFlags, run: runWatch},
+	{name: "serve", summary: "Run download jobs submitted over an HTTP API", flags: serveFlags, run: runServe},
+}
+
+// Execute runs the command named by the first argument and returns the
+// process exit code.
+//
+// Input:
+// - args: the command line without the executable name.
+//
+// Notes:
+// - Without a command, e.g. `url-downloader -f list.csv` or `url-downloader lists/*.csv`, the arguments are those of the download command.
+// - `url-downloader help <command>` and `url-downloader <command> -h` print the help of a command, generated from its flags.
+func Execute(args []string) int {
+	cmd := findCommand("download")
+	if len(args) > 0 {
+		switch name := args[0]; {
+		case name == "-h" || name == "--help" || name == "-help":
+			printCommands(os.Stdout)
+			return ExitSuccess
+		case name == "help":
+			if len(args) == 1 {
+				printCommands(os.Stdout)
+				return ExitSuccess
+			}
+			if cmd = findCommand(args[1]); cmd == nil {
+				return unknownCommand(args[1])
+			}
+			args = []string{"-h"}
+		case name == "version":
+			PrintVersionAndExit(VERSION)
+		case findCommand(name) != nil:
+			cmd, args = findCommand(name), args[1:]
+		case !strings.HasPrefix(name, "-") && !fileExists(name) && !hasGlobMeta(name):
+			return unknownCommand(name)
+		}
+	}
+
+	if err := parseCommand(cmd, args); errors.Is(err, flag.ErrHelp) {
+		return ExitSuccess
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", GetExeName(), cmd.name, err)
+		return ExitConfigError
+	}
+	return cmd.run()
+}
+
+// findCommand returns the command called name, nil if there is none.
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// unknownCommand reports an unknown command and returns ExitConfigError.
+func unknownCommand(name string) int {
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", GetExeName(), name)
+	printCommands(os.Stderr)
+	return ExitConfigError
+}
+
+// parseCommand parses the flags of cmd, then completes them from the
+// environment and the configuration file (see applyConfig).
+//
+// Output:
+// - Returns flag.ErrHelp once the help of the command is printed.
+// - Returns an error if a flag or option is invalid.
+func parseCommand(cmd *command, args []string) error {
+	known := configKeys() // before registering the flags, see configKeys
+
+	fs := flag.NewFlagSet(MODULE_NAME+" "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard) // errors are returned, the help is printed by printUsage
+	fs.BoolVar(&showHelp, "h", false, "Show this message")
+	fs.BoolVar(&showHelp, "help", false, "Show this message")
+	configure := cmd.flags(fs)
+
+	positional, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) || showHelp {
+		printUsage(os.Stdout, cmd, fs)
+		return flag.ErrHelp
+	}
+	if err != nil {
+		return err
+	}
+
+	if showVersion {
+		PrintVersionAndExit(VERSION)
+	}
+
+	// Options missing from the command line come from the environment or the configuration file
+	if fs.Lookup("config") != nil {
+		if err := applyConfig(fs, configFile, known); err != nil {
+			return err
+		}
+	}
+	return configure(positional)
+}
+
+// parseInterspersed parses fs, accepting flags after the positional arguments
+// as in `url-downloader retry manifest.jsonl --workers 8`, and returns the
+// positional arguments. Arguments after "--" are all positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// configKeys returns the long flag names of every command, so that one
+// configuration file can hold the options of several commands.
+//
+// Notes:
+// - Registering the flags resets the options to their defaults, it must run before the flags of the command are registered.
+func configKeys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, cmd := range commands {
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		cmd.flags(fs)
+		fs.VisitAll(func(f *flag.Flag) { keys[configKey(f.Name)] = true })
+	}
+	return keys
+}
+
+// printCommands prints the commands of url-downloader.
+func printCommands(out io.Writer) {
+	fmt.Fprintf(out, "Usage: %s <command> [options] [arguments]\n\nCommands:\n", MODULE_NAME)
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t%s\t%s\n", cmd.name, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\nRun '%s help <command>' for the options of a command.\n", MODULE_NAME)
+	fmt.Fprintf(out, "Without a command the arguments are those of the download command.\n")
+	printFooter(out)
+}
+
+// printUsage prints the help of cmd, generated from the flags registered in fs.
+//
+// Notes:
+// - A short flag is listed with its long name, e.g. `-f, --file <file>`.
+// - The argument name is the back quoted word of the flag usage, see flag.UnquoteUsage.
+func printUsage(out io.Writer, cmd *command, fs *flag.FlagSet) {
+	usage := strings.TrimSpace(fmt.Sprintf("%s %s [options] %s", MODULE_NAME, cmd.name, cmd.args))
+	fmt.Fprintf(out, "Usage: %s\n\n%s\n\nOptions:\n", usage, cmd.summary)
+
+	shortNames := make(map[string]string)
+	for short, long := range flagAliases {
+		if fs.Lookup(short) != nil {
+			shortNames[long] = short
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fs.VisitAll(func(f *flag.Flag) {
+		if configKey(f.Name) != f.Name {
+			return // listed with its long name
+		}
+		names := "--" + f.Name
+		if short, ok := shortNames[f.Name]; ok {
+			names = "-" + short + ", " + names
+		}
+		arg, usage := flag.UnquoteUsage(f)
+		if arg != "" {
+			names += " <" + arg + ">"
+		}
+		switch f.DefValue {
+		case "", "0", "false", "0s", "[]":
+		default:
+			usage += " (default: " + f.DefValue + ")"
+		}
+		fmt.Fprintf(w, "\t%s\t%s\n", names, usage)
+	})
+	w.Flush()
+
+	if fs.Lookup("config") != nil {
+		fmt.Fprintf(out, "\nConfiguration precedence:\n\tcommand line, then %s<OPTION> environment variables (e.g. %s), then --config, then defaults\n", ENV_PREFIX, envName("max-size"))
+	}
+	printFooter(out)
+}
+
+// printFooter prints the exit codes shared by every command.
+func printFooter(out io.Writer) {
+	fmt.Fprintf(out, "\nExit codes:\n\t0 success, 1 error, 2 invalid options, 3 partial failure, 4 total failure, 5 interrupted\n")
+}
+
+// runDownload runs the download and retry commands.
+func runDownload() int {
+	if dryRun {
+		return runDryRun()
+	}
+	// This is a blocking call
+	result, err := Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+	log.Printf("Application closed: %s", result.Status)
+	return ExitCode(result)
+}
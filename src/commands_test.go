@@ -0,0 +1,55 @@
+package src
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// Test the help of a command is generated from its flags
+func TestPrintUsage(t *testing.T) {
+	cmd := findCommand("download")
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.flags(fs)
+
+	var out strings.Builder
+	printUsage(&out, cmd, fs)
+	help := out.String()
+	for _, expected := range []string{
+		"Usage: url-downloader download [options] [<file.csv>...]",
+		"-f, --file <file>",
+		"--workers <n>",
+		"Run n concurrent downloads (default: 50)",
+		"--header <header>",
+		"URL_DOWNLOADER_MAX_SIZE",
+	} {
+		if !strings.Contains(help, expected) {
+			t.Errorf("Expected help to contain %q, got:\n%s", expected, help)
+		}
+	}
+	if strings.Contains(help, "  -f <file>") {
+		t.Errorf("Expected -f to be listed with --file only, got:\n%s", help)
+	}
+}
+
+// Test flags are accepted after the positional arguments
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	workers := fs.Int("workers", 0, "")
+	args, err := parseInterspersed(fs, []string{"manifest.jsonl", "--workers", "8", "--", "-x"})
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if *workers != 8 || strings.Join(args, " ") != "manifest.jsonl -x" {
+		t.Errorf("Expected workers 8 and [manifest.jsonl -x], got %d and %v", *workers, args)
+	}
+}
+
+// Test unknown commands and missing arguments are configuration errors
+func TestExecute_Errors(t *testing.T) {
+	for _, args := range [][]string{{"unknown"}, {"verify"}, {"retry", "a.jsonl", "b.jsonl"}, {"download", "a.csv", "b.csv"}} {
+		if code := Execute(args); code != ExitConfigError {
+			t.Errorf("Execute(%v) = %d, expected %d", args, code, ExitConfigError)
+		}
+	}
+}
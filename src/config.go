@@ -0,0 +1,212 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const ENV_PREFIX = "URL_DOWNLOADER_" // environment variables overriding the configuration file
+
+// flagAliases maps the short flags to the long name used in the configuration
+// file and the environment.
+var flagAliases = map[string]string{"f": "file", "h": "help", "v": "version"}
+
+// optionSources records where every option not given on the command line came
+// from, e.g. `environment variable URL_DOWNLOADER_MAX_SIZE`, for error messages.
+var optionSources = map[string]string{}
+
+// applyConfig fills the options not given on the command line from the
+// environment and from the configuration file.
+//
+// Input:
+// - fs: the parsed flag set.
+// - configFile: YAML (.yaml/.yml), TOML (.toml) or JSON (.json) file, empty for none.
+// - known: the keys of every command, see configKeys.
+//
+// Output:
+// - Returns an error naming the offending key and its source if a key is unknown or its value is invalid.
+//
+// Notes:
+// - Precedence: command line, then URL_DOWNLOADER_* environment variables, then the configuration file, then the defaults.
+// - Keys are the long flag names, e.g. `max-size`; the environment variable of a key is URL_DOWNLOADER_ followed by the upper cased key with dashes replaced by underscores.
+// - Keys of the other commands are ignored so that one file can serve them all.
+// - List options (allow-type, header, ...) accept a list in the configuration file; in the environment they are comma separated, except header which takes a single header.
+func applyConfig(fs *flag.FlagSet, configFile string, known map[string]bool) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[configKey(f.Name)] = true })
+	for name := range optionSources {
+		delete(optionSources, name)
+	}
+
+	// Environment variables
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		key := configKey(f.Name)
+		if envErr != nil || key != f.Name || given[key] || !configurable(key) {
+			return
+		}
+		env := envName(key)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return
+		}
+		given[key] = true
+		optionSources[key] = "environment variable " + env
+		if err := fs.Set(key, value); err != nil {
+			envErr = fmt.Errorf("environment variable %s: %v", env, err)
+		}
+	})
+	if envErr != nil || configFile == "" {
+		return envErr
+	}
+
+	// Configuration file
+	values, err := loadConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedConfigKeys(values) {
+		f := fs.Lookup(key)
+		if f == nil && known[key] && configurable(key) {
+			continue // an option of another command
+		}
+		if f == nil || configKey(key) != key || !configurable(key) {
+			return fmt.Errorf("config file %s: unknown key %q", configFile, key)
+		}
+		if given[key] {
+			continue
+		}
+		items, err := configValue(values[key])
+		if err != nil {
+			return fmt.Errorf("config file %s: key %q: %v", configFile, key, err)
+		}
+		if _, repeatable := f.Value.(*headerList); !repeatable {
+			items = []string{strings.Join(items, ",")}
+		}
+		for _, item := range items {
+			if err := fs.Set(key, item); err != nil {
+				return fmt.Errorf("config file %s: key %q: %v", configFile, key, err)
+			}
+		}
+		optionSources[key] = "config file " + configFile
+	}
+	return nil
+}
+
+// loadConfigFile decodes the configuration file according to its extension.
+func loadConfigFile(configFile string) (map[string]any, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %v", err)
+	}
+
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		_, err = toml.Decode(string(data), &values)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber() // keep integers such as max-size exact
+		err = decoder.Decode(&values)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml, .toml or .json", configFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %v", configFile, err)
+	}
+	return values, nil
+}
+
+// configValue turns a decoded value into the flag values to set.
+func configValue(value any) ([]string, error) {
+	switch v := value.(type) {
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			scalar, err := configScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, scalar)
+		}
+		return items, nil
+	default:
+		scalar, err := configScalar(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{scalar}, nil
+	}
+}
+
+// configScalar formats a string, number or boolean the way the flag parses it.
+func configScalar(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case nil:
+		return "", fmt.Errorf("missing value")
+	}
+	return "", fmt.Errorf("expected a string, number or boolean, got %T", value)
+}
+
+// configKey returns the long name of a flag.
+func configKey(name string) string {
+	if long, ok := flagAliases[name]; ok {
+		return long
+	}
+	return name
+}
+
+// configurable reports whether key may be set outside of the command line.
+func configurable(key string) bool {
+	return key != "help" && key != "version" && key != "config"
+}
+
+// envName returns the environment variable overriding key.
+func envName(key string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// sortedConfigKeys returns the keys of the configuration file in a stable order.
+func sortedConfigKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// invalidOption returns a validation error for key, naming where its value came from.
+func invalidOption(key string, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if source, ok := optionSources[key]; ok {
+		return fmt.Errorf("%s: key %q: %s", source, key, msg)
+	}
+	return fmt.Errorf("--%s: %s", key, msg)
+}
@@ -0,0 +1,154 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// configure runs Configure on a fresh set of options
+func configure(args ...string) error {
+	options = downloader.Options{}
+	return Configure(args)
+}
+
+// writeConfig writes a configuration file named name and returns its path
+func writeConfig(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+// Test command line, environment and configuration file precedence
+func TestConfigure_Precedence(t *testing.T) {
+	config := writeConfig(t, "config.yaml", `
+file: ../testdata/valid.csv
+max-size: 1048576
+workers: 8
+keep-alive: 1m
+header:
+  - "X-Team: data"
+  - "X-Env: test"
+allow-type: [text/*, application/pdf]
+`)
+	t.Setenv("URL_DOWNLOADER_WORKERS", "4")
+
+	if err := configure("--config", config, "--max-size", "10"); err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if options.MaxBodySize != 10 {
+		t.Errorf("Expected the command line max-size 10, got %d", options.MaxBodySize)
+	}
+	if options.Workers != 4 {
+		t.Errorf("Expected the environment workers 4, got %d", options.Workers)
+	}
+	if options.Transport.KeepAlive != time.Minute || csvFilePath != "../testdata/valid.csv" {
+		t.Errorf("Expected keep-alive and file from the config file, got %v and %q", options.Transport.KeepAlive, csvFilePath)
+	}
+	if len(options.Headers) != 2 || strings.Join(options.AllowTypes, ",") != "text/*,application/pdf" {
+		t.Errorf("Expected the config file lists, got %v and %v", options.Headers, options.AllowTypes)
+	}
+}
+
+// Test the TOML and JSON formats
+func TestConfigure_Formats(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.toml": "file = \"../testdata/valid.csv\"\nmax-failure-ratio = 0.25\nrobots = true\n",
+		"config.json": `{"file": "../testdata/valid.csv", "max-failure-ratio": 0.25, "robots": true}`,
+	} {
+		if err := configure("--config", writeConfig(t, name, content)); err != nil {
+			t.Fatalf("%s: expected success but got error: %v", name, err)
+		}
+		if options.MaxFailureRatio != 0.25 || !options.RespectRobots {
+			t.Errorf("%s: expected max-failure-ratio 0.25 and robots, got %v and %v", name, options.MaxFailureRatio, options.RespectRobots)
+		}
+	}
+}
+
+// Test errors point at the offending key and where it came from
+func TestConfigure_Errors(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"file: ../testdata/valid.csv\nmax-sise: 10\n", `unknown key "max-sise"`},
+		{"file: ../testdata/valid.csv\nmax-size: big\n", `key "max-size": parse error`},
+		{"file: ../testdata/valid.csv\nmax-redirects: -1\n", `key "max-redirects": must not be negative`},
+		{"file: ../testdata/valid.csv\nproxy: {url: x}\n", `key "proxy": expected a string, number or boolean`},
+	}
+	for _, test := range tests {
+		config := writeConfig(t, "config.yaml", test.content)
+		err := configure("--config", config)
+		if err == nil || !strings.Contains(err.Error(), test.expected) || !strings.Contains(err.Error(), config) {
+			t.Errorf("Expected error with %q naming %s, got %v", test.expected, config, err)
+		}
+	}
+
+	t.Setenv("URL_DOWNLOADER_TIMEOUT", "soon")
+	if err := configure("-f", "../testdata/valid.csv"); err == nil || !strings.Contains(err.Error(), "URL_DOWNLOADER_TIMEOUT") {
+		t.Errorf("Expected error naming URL_DOWNLOADER_TIMEOUT, got %v", err)
+	}
+}
+
+// Test the options of other commands are ignored so that one file serves every command
+func TestConfigure_OtherCommandKeys(t *testing.T) {
+	config := writeConfig(t, "config.yaml", "file: ../testdata/valid.csv\njobs-dir: /var/lib/url-downloader\n")
+	if err := configure("--config", config); err != nil {
+		t.Errorf("Expected the serve option jobs-dir to be ignored, got %v", err)
+	}
+}
+
+// Test several input files and glob patterns are expanded in order, once each
+func TestConfigure_Inputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.csv", "b.csv", "c.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("url\n"), 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+	}
+	a, b, c := filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.csv"), filepath.Join(dir, "c.jsonl")
+
+	if err := configure("-f", filepath.Join(dir, "*.csv"), c, a); err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if strings.Join(inputFiles, ",") != strings.Join([]string{a, b, c}, ",") || csvFilePath != a {
+		t.Errorf("Expected the inputs %s, %s and %s, got %v (%s)", a, b, c, inputFiles, csvFilePath)
+	}
+
+	if err := configure(filepath.Join(dir, "*.txt")); err == nil || !strings.Contains(err.Error(), "no file matches") {
+		t.Errorf("Expected an error for a pattern without match, got %v", err)
+	}
+	if err := configure(a, filepath.Join(dir, "missing.csv")); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected an error for a missing input, got %v", err)
+	}
+
+	// The same file given twice is read once, inputs sharing their outputs are refused
+	if err := configure(dir+"/./a.csv", a); err != nil || len(inputFiles) != 1 {
+		t.Errorf("Expected %s to be read once, got %v (%v)", a, inputFiles, err)
+	}
+	other := filepath.Join(dir, "other")
+	if err := os.Mkdir(other, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for _, name := range []string{"a.jsonl", "other/a.csv", "a.v1.csv", "a.v2.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("url\n"), 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+	}
+	if err := configure(a, filepath.Join(dir, "a.jsonl")); err == nil || !strings.Contains(err.Error(), "would both write") {
+		t.Errorf("Expected an error for a.csv and a.jsonl, got %v", err)
+	}
+	if err := configure("--output-dir", t.TempDir(), a, filepath.Join(other, "a.csv")); err == nil || !strings.Contains(err.Error(), "would both download") {
+		t.Errorf("Expected an error for two a.csv with --output-dir, got %v", err)
+	}
+	if err := configure("--output-dir", t.TempDir(), filepath.Join(dir, "a.v1.csv"), filepath.Join(dir, "a.v2.csv")); err != nil {
+		t.Errorf("Expected a.v1.csv and a.v2.csv to download apart, got %v", err)
+	}
+}
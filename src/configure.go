@@ -1,8 +1,16 @@
 package src
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+	"github.com/rs/zerolog"
 )
 
 const (
@@ -10,69 +18,336 @@ const (
 	MODULE_NAME = "url-downloader"
 )
 
-var usageStr = `
-Usage: url-downloader [options]
-
-Command line options: (Mandatory)
-        -f, --file <file> absolute path of csv file.
-Other Options:
-	-h, --help	Show this message
-	-v, --version	Show version
-`
-
 var (
 	showVersion bool
 	showHelp    bool
 	csvFilePath string
+	inputFiles  []string // input files of the run, csvFilePath is the first one
+
+	configFile string             // configuration file, empty for none
+	options    downloader.Options // download, request, transport and redirect options of the run
+	runTimeout time.Duration      // deadline of the whole run
+	outputDir  string             // directory of the downloaded files, empty for <file_without_extension>/downloads
+	logLevel   string             // minimum level written to the log
+
+	metricsAddr string // Prometheus metrics listen address, empty disables the server
+
+	progressMode     string        // one of ProgressAuto, ProgressTTY, ProgressPlain, ProgressOff
+	progressInterval time.Duration // interval of plain progress lines
+
+	reportFile      string // JSON run report, empty disables it
+	junitReportFile string // JUnit XML run report, empty disables it
+
+	retryFile    string   // manifest or report of the run to retry, empty for a normal run
+	retryClasses []string // failure classes to retry, empty retries all
+
+	onPersisted string // shell command run for every persisted file, empty disables it
+
+	dryRun     bool // print the plan of the run instead of downloading
+	dryRunHead bool // issue HEAD requests to estimate the size of the plan
+
+	manifestFile string // manifest read by the report and verify commands
+	jsonOutput   bool   // print the result of the validate, report and verify commands as JSON
+
+	serveAddr    string // listen address of the serve command
+	apiToken     string // bearer token required by the job API, empty accepts every client
+	allowPrivate bool   // let the jobs of the serve command download private addresses
+	jobsDir      string // directory of the jobs run by the serve command
+	maxJobs      int    // jobs run at once by the serve command
+
+	watchDir      string        // spool directory of the watch command
+	watchPoll     bool          // poll watchDir instead of using inotify
+	watchInterval time.Duration // interval of the polling scans of watchDir
 )
 
-// ConfigureOptions accepts a flag set and augments it with URL Downloaded
-// specific flags. On success, an options structure is returned configured
-// based on the selected flags and/or configuration file.
-// The command line options take precedence to the ones in the configuration file.
+// headerList collects repeated `--header "Name: value"` flags.
+type headerList []string
+
+func (h *headerList) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerList) Set(value string) error {
+	if name, _, ok := strings.Cut(value, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q must be in the form \"Name: value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// Configure parses the arguments of the download command, i.e. of
+// `url-downloader [download] [options]`. The command line options take
+// precedence to the ones in the environment, which take precedence to the
+// ones in the configuration file (see applyConfig).
 func Configure(args []string) error {
-	// Create a FlagSet and sets the usage
-	fs := flag.NewFlagSet(MODULE_NAME, flag.ExitOnError)
-	fs.Usage = PrintHelpAndExit
-	fs.BoolVar(&showHelp, "h", false, "Show this message")
-	fs.BoolVar(&showHelp, "help", false, "Show this message")
+	return parseCommand(findCommand("download"), args)
+}
+
+// downloadFlags registers the flags of the download command.
+func downloadFlags(fs *flag.FlagSet) func(args []string) error {
 	fs.BoolVar(&showVersion, "v", false, "Show version")
 	fs.BoolVar(&showVersion, "version", false, "Show version")
-	fs.StringVar(&csvFilePath, "f", "", "absolute path of csv file")
-	fs.StringVar(&csvFilePath, "file", "", "absolute path of csv file")
+	fs.StringVar(&csvFilePath, "f", "", "Absolute path of the csv or jsonl `file`, or a glob pattern of several ones; more files are accepted as arguments")
+	fs.StringVar(&csvFilePath, "file", "", "Absolute path of the csv or jsonl `file`, or a glob pattern of several ones; more files are accepted as arguments")
+	fs.StringVar(&retryFile, "retry", "", "Retry the failed URLs of a previous run instead, like the retry command (manifest.jsonl or --report `file`)")
+	configureRun := runFlags(fs)
 
-	if err := fs.Parse(args); err != nil {
-		return err
+	return func(args []string) error {
+		return configureRun(args)
 	}
+}
+
+// retryFlags registers the flags of the retry command.
+func retryFlags(fs *flag.FlagSet) func(args []string) error {
+	configureRun := runFlags(fs)
 
-	if showVersion {
-		PrintVersionAndExit(VERSION)
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected the manifest or report of the run to retry")
+		}
+		retryFile = args[0]
+		return configureRun(nil)
 	}
+}
+
+// runFlags registers the flags of a download run, shared by the download and
+// retry commands, and returns the function completing their configuration from
+// the input files given as arguments.
+func runFlags(fs *flag.FlagSet) func(args []string) error {
+	fs.StringVar(&configFile, "config", os.Getenv(ENV_PREFIX+"CONFIG"), "YAML, TOML or JSON `file` setting any option by its long name (e.g. max-size: 1048576)")
+	fs.DurationVar(&runTimeout, "timeout", SHUTDOWN_DEAD_LINE, "Deadline of the whole run")
+	fs.StringVar(&outputDir, "output-dir", "", "Write the downloaded files into `dir` (default: <file_without_extension>/downloads)")
+	fs.StringVar(&logLevel, "log-level", zerolog.LevelDebugValue, "Log `level`: debug, info, warn or error")
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics on `addr` (e.g. :9100) at /metrics")
+	fs.StringVar(&progressMode, "progress", ProgressAuto, "Progress display `mode`: auto, tty, plain or off")
+	fs.DurationVar(&progressInterval, "progress-interval", 10*time.Second, "Interval of plain progress lines")
+	fs.StringVar(&reportFile, "report", "", "Write a JSON run report to `file`")
+	fs.StringVar(&junitReportFile, "junit-report", "", "Write a JUnit XML run report to `file`")
+	retryClassList := fs.String("retry-class", "", "Comma separated failure `classes` to retry (default: all)")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the URLs, hosts, targets and rejected rows of the run without downloading anything")
+	fs.BoolVar(&dryRunHead, "dry-run-head", false, "With --dry-run, issue a HEAD request per URL to estimate sizes and apply the size and Content-Type limits")
+	fs.StringVar(&onPersisted, "on-persisted", "", "Shell `command` run for every saved file, with URL_DOWNLOADER_URL and URL_DOWNLOADER_PATH set")
+	configureOptions := optionFlags(fs)
 
-	if showHelp {
-		fs.Usage()
+	return func(args []string) error {
+		// A retry writes into the output directory of the run it retries
+		retryClasses = splitList(*retryClassList)
+		if retryFile != "" {
+			if !fileExists(retryFile) {
+				return fmt.Errorf("retry file is not found :%s", retryFile)
+			}
+			input, err := retryInputPath(retryFile)
+			if err != nil {
+				return err
+			}
+			csvFilePath, inputFiles = input, []string{input}
+		}
+
+		if err := configureOptions(); err != nil {
+			return err
+		}
+		if retryFile == "" {
+			// --file may come from the configuration file, read by configureOptions
+			if err := configureInputs(args); err != nil {
+				return err
+			}
+		}
+		return postValidator()
 	}
+}
 
-	if csvFilePath=="" && len(args)>0{
-		csvFilePath=args[0]
+// urlFlags registers the URL normalization flags, shared with the validate command.
+func urlFlags(fs *flag.FlagSet) {
+	fs.StringVar(&options.URLs.DefaultScheme, "default-scheme", "https", "Use `scheme` (http or https) for the URLs read without one")
+	fs.BoolVar(&options.URLs.StripTracking, "strip-tracking", false, "Remove tracking query parameters (utm_*, fbclid, gclid, ...) from the URLs")
+	fs.BoolVar(&options.URLs.RejectPrivate, "reject-private", false, "Reject URLs to localhost and to loopback, private and link-local addresses")
+}
+
+// optionFlags registers the flags of downloader.Options, shared by every
+// command that downloads, and returns the function filling the options
+// derived from them once parsed.
+func optionFlags(fs *flag.FlagSet) func() error {
+	fs.IntVar(&options.Workers, "workers", downloader.MAX_WORKERS, "Run `n` concurrent downloads")
+	fs.IntVar(&options.LookAhead, "lookahead", 1000, "Read up to `n` URLs ahead to download the higher priorities first, 0 keeps the input order")
+	urlFlags(fs)
+	fs.BoolVar(&options.HTTPFallback, "http-fallback", false, "Retry URLs read without a scheme over http when https fails to connect or to handshake")
+	fs.Int64Var(&options.MaxBodySize, "max-size", 0, "Skip responses larger than this many `bytes` (0 = unlimited)")
+	allowTypeList := fs.String("allow-type", "", "Comma separated Content-Type `patterns` to accept (e.g. text/*,application/pdf)")
+	denyTypeList := fs.String("deny-type", "", "Comma separated Content-Type `patterns` to reject")
+	fs.StringVar(&options.UserAgent, "user-agent", MODULE_NAME+"/"+VERSION, "Send `ua` as the User-Agent header of every request")
+	fs.Var((*headerList)(&options.Headers), "header", "Extra \"Name: value\" request `header` (repeatable)")
+	user := fs.String("user", "", "Basic authentication credentials as `user:password`")
+	tokenFile := fs.String("bearer-token-file", "", "Read a bearer token from `file`")
+	tokenEnv := fs.String("bearer-token-env", "", "Read a bearer token from the environment `variable`")
+	netrcFile := fs.String("netrc", "", ".netrc `file` with per host credentials")
+	authHostList := fs.String("auth-host", "", "Comma separated `hosts`, with their subdomains, that --user, the bearer token and the netrc default entry are sent to (default: every host)")
+	fs.StringVar(&options.Transport.Proxy, "proxy", "", "HTTP, HTTPS or SOCKS5 proxy `url` (default: HTTP_PROXY/HTTPS_PROXY environment)")
+	noProxyList := fs.String("no-proxy", "", "Comma separated `hosts` that bypass the proxy")
+	fs.StringVar(&options.Transport.CACertFile, "ca-cert", "", "PEM CA bundle `file` used to verify servers")
+	fs.StringVar(&options.Transport.ClientCertFile, "client-cert", "", "PEM client certificate `file` for mTLS")
+	fs.StringVar(&options.Transport.ClientKeyFile, "client-key", "", "PEM client key `file` for mTLS")
+	fs.BoolVar(&options.Transport.InsecureSkipVerify, "insecure", false, "Skip TLS certificate verification")
+	fs.IntVar(&options.Transport.MaxIdleConnsPerHost, "max-idle-conns-per-host", downloader.MAX_WORKERS, "Keep up to `n` idle connections per host")
+	enableHTTP2 := fs.Bool("http2", true, "Allow HTTP/2, use --http2=false to disable")
+	fs.DurationVar(&options.Transport.KeepAlive, "keep-alive", 30*time.Second, "TCP keep-alive period")
+	fs.DurationVar(&options.Transport.IdleConnTimeout, "idle-conn-timeout", 90*time.Second, "How long idle connections are kept")
+	fs.BoolVar(&options.Transport.DisableKeepAlives, "disable-keep-alives", false, "Use a new connection for every request")
+	fs.IntVar(&options.Redirects.MaxRedirects, "max-redirects", 10, "Follow up to `n` redirects, 0 disables following")
+	fs.BoolVar(&options.Redirects.SameHost, "redirect-same-host", false, "Only follow redirects to the original host")
+	fs.BoolVar(&options.Redirects.ForbidDowngrade, "forbid-downgrade", false, "Refuse redirects from https to http")
+	fs.BoolVar(&options.RespectRobots, "robots", false, "Honor robots.txt rules and Crawl-delay of every host")
+	fs.Float64Var(&options.MaxFailureRatio, "max-failure-ratio", 0, "Allowed `fraction` of failed URLs while still exiting 0")
+
+	return func() error {
+		options.AllowTypes = splitList(*allowTypeList)
+		options.DenyTypes = splitList(*denyTypeList)
+		options.BasicAuth = downloader.ParseBasicAuth(*user)
+		options.AuthHosts = splitList(*authHostList)
+		options.Transport.NoProxy = splitList(*noProxyList)
+		options.Transport.DisableHTTP2 = !*enableHTTP2
+		options.Redirects.NoFollow = options.Redirects.MaxRedirects == 0
+
+		var err error
+		if options.BearerToken, err = downloader.LoadBearerToken(*tokenFile, *tokenEnv); err != nil {
+			return err
+		}
+		if *netrcFile != "" {
+			if options.Netrc, err = downloader.LoadNetrc(*netrcFile); err != nil {
+				return fmt.Errorf("reading netrc file: %v", err)
+			}
+		}
+		return nil
 	}
+}
 
-	if err := postValidator(); err != nil {
-		PrintAndDie(err.Error())
+// configureInputs sets the input files of a download run: --file followed by
+// the arguments, with their glob patterns expanded.
+func configureInputs(args []string) error {
+	patterns := args
+	if csvFilePath != "" {
+		patterns = append([]string{csvFilePath}, args...)
 	}
+	inputs, err := expandInputs(patterns)
+	if err != nil {
+		return err
+	}
+	inputFiles = inputs
+	if len(inputs) > 0 {
+		csvFilePath = inputs[0]
+	}
+	return nil
+}
+
+// expandInputs expands the glob patterns of the input files, e.g. `lists/*.csv`,
+// and drops the files given twice. Paths without glob characters are only cleaned.
+func expandInputs(patterns []string) ([]string, error) {
+	var inputs []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if hasGlobMeta(pattern) {
+			var err error
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, invalidOption("file", "invalid pattern %q: %v", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, invalidOption("file", "no file matches %q", pattern)
+			}
+		}
+		for _, match := range matches {
+			match = filepath.Clean(match) // ./a.csv and a.csv are the same input
+			if !seen[match] {
+				seen[match] = true
+				inputs = append(inputs, match)
+			}
+		}
+	}
+	return inputs, nil
+}
 
+// checkOutputCollisions rejects the inputs that would write to the same files:
+// a.csv and a.jsonl share <file_without_extension>/ and, with --output-dir,
+// lists/a.csv and other/a.csv share <output-dir>/a.
+func checkOutputCollisions(inputs []string) error {
+	bases := make(map[string]string, len(inputs))
+	dirs := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		base := getOutputBase(input)
+		if other, ok := bases[base]; ok {
+			return invalidOption("file", "%s and %s would both write to %s", other, input, base)
+		}
+		bases[base] = input
+		dir := downloadDir(input)
+		if other, ok := dirs[dir]; ok {
+			return invalidOption("file", "%s and %s would both download to %s", other, input, dir)
+		}
+		dirs[dir] = input
+	}
 	return nil
 }
 
+// hasGlobMeta reports whether path holds glob characters, see filepath.Match.
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// postValidator checks the configuration of a download run.
 func postValidator() error {
 	if csvFilePath == "" {
 		return fmt.Errorf("csv filepath is mandatory")
 	}
-	if !fileExists(csvFilePath) {
-		return fmt.Errorf("csv filepath is not found :%s", csvFilePath)
+	for _, input := range inputFiles {
+		if retryFile != "" {
+			break // a retry reads its manifest or report, checked by runFlags
+		}
+		if !fileExists(input) {
+			return invalidOption("file", "csv filepath is not found :%s", input)
+		}
+		if ext := GetFileExtension(input); ext != "csv" && ext != "jsonl" {
+			return invalidOption("file", "invalid extension: %s", input)
+		}
 	}
-	if GetFileExtension(csvFilePath) != "csv" {
-		return fmt.Errorf("invalid extension")
+	if err := checkOutputCollisions(inputFiles); err != nil {
+		return err
+	}
+	switch progressMode {
+	case ProgressAuto, ProgressTTY, ProgressPlain, ProgressOff:
+	default:
+		return invalidOption("progress", "invalid progress mode %q", progressMode)
+	}
+	if progressInterval <= 0 {
+		return invalidOption("progress-interval", "must be positive")
+	}
+	if dryRunHead && !dryRun {
+		return invalidOption("dry-run-head", "requires --dry-run")
+	}
+	return validateOptions()
+}
+
+// validateOptions checks the options shared by every command that downloads.
+func validateOptions() error {
+	if options.Workers < 1 {
+		return invalidOption("workers", "must be at least 1")
+	}
+	if runTimeout <= 0 {
+		return invalidOption("timeout", "must be positive")
+	}
+	if _, err := zerolog.ParseLevel(logLevel); err != nil || logLevel == "" {
+		return invalidOption("log-level", "unknown level %q", logLevel)
+	}
+	if options.Transport.MaxIdleConnsPerHost < 1 {
+		return invalidOption("max-idle-conns-per-host", "must be at least 1")
+	}
+	return checkOptions()
+}
+
+// checkOptions runs options.Validate, reporting an invalid option with its flag.
+func checkOptions() error {
+	var optionErr *downloader.OptionError
+	if err := options.Validate(); errors.As(err, &optionErr) {
+		return invalidOption(optionErr.Option, "%s", optionErr.Reason)
+	} else if err != nil {
+		return err
 	}
 	return nil
 }
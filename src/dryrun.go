@@ -0,0 +1,102 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+	"github.com/rs/zerolog"
+)
+
+// runDryRun prints the plan of the download or retry command instead of running it.
+//
+// Output:
+// - Prints the plan, and writes it as JSON to --report if given.
+// - Returns the exit code of the validate command: partial or total failure if rows would be rejected.
+//
+// Notes:
+// - Nothing is written to the output directory, no manifest entry is recorded and no hook runs; only the log file is written.
+func runDryRun() int {
+	var err error
+	level, _ := zerolog.ParseLevel(logLevel) // validated by postValidator
+	if err, zlog = initLogger(csvFilePath, false, level); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+
+	options.Logger = zlog
+	d, err := downloader.New(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+	source, err := newSource()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+
+	// The sinks only name the targets, their directories are not created
+	sinks := make(map[string]downloader.Sink, len(inputFiles))
+	for _, input := range inputFiles {
+		sinks[input] = &downloader.DirSink{Dir: downloadDir(input)}
+	}
+	plan, err := d.Plan(ctx, source, downloader.InputSinks{Sinks: sinks, Default: sinks[csvFilePath]}, dryRunHead)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: planning: %s\n", GetExeName(), err)
+		return ExitError
+	}
+
+	printPlan(os.Stdout, plan)
+	if reportFile != "" {
+		if err := plan.WriteJSON(reportFile); err != nil {
+			zlog.Error().Msgf("Error writing report: %v", err)
+		}
+	}
+	return checkExitCode(len(plan.Rejected), plan.Read-plan.Duplicates)
+}
+
+// printPlan prints a plan as text.
+func printPlan(out io.Writer, plan downloader.Plan) {
+	fmt.Fprintf(out, "Dry run, nothing is downloaded\n")
+	fmt.Fprintf(out, "URLs: %d read, %d planned, %d duplicates, %d rejected\n", plan.Read, len(plan.URLs), plan.Duplicates, len(plan.Rejected))
+	fmt.Fprintf(out, "Estimated bytes: %d", plan.EstimatedBytes)
+	if plan.UnknownSizes > 0 {
+		fmt.Fprintf(out, " (%d URLs of unknown size)", plan.UnknownSizes)
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Hosts: %d\n", len(plan.Hosts))
+	for _, host := range sortedCounts(plan.Hosts) {
+		fmt.Fprintf(w, "\t%s\t%d\n", host, plan.Hosts[host])
+	}
+	if len(plan.URLs) > 0 {
+		fmt.Fprintln(w, "Targets:")
+	}
+	for _, planned := range plan.URLs {
+		size := "unknown size"
+		if planned.Size >= 0 {
+			size = fmt.Sprintf("%d bytes", planned.Size)
+		}
+		fmt.Fprintf(w, "\t%s\t-> %s\t%s\t%s\n", planned.URL, planned.Target, size, planned.ContentType)
+	}
+	w.Flush()
+
+	if len(plan.Rejected) > 0 {
+		fmt.Fprintln(out, "Rejected:")
+	}
+	for _, rejected := range plan.Rejected {
+		fmt.Fprintf(out, "\t%s: %s: %q\n", rejected.Input, rejected.Reason, rejected.Text)
+	}
+}
@@ -0,0 +1,38 @@
+package src
+
+import "github.com/garunkumar450/url-downloader/downloader"
+
+// Process exit codes returned by the url-downloader commands.
+const (
+	ExitSuccess        = 0 // every URL was downloaded, or failures stayed within --max-failure-ratio
+	ExitError          = 1 // the run could not start, e.g. the log or manifest could not be created
+	ExitConfigError    = 2 // invalid command line options
+	ExitPartialFailure = 3 // some URLs failed beyond --max-failure-ratio, or some rows or files are invalid (validate, verify)
+	ExitTotalFailure   = 4 // no URL was downloaded successfully, or no row or file is valid
+	ExitInterrupted    = 5 // the run was cut short by a signal or the shutdown deadline
+)
+
+// ExitCode maps the status of a run to the process exit code.
+func ExitCode(result downloader.Result) int {
+	switch result.Status {
+	case downloader.StatusPartialFailure:
+		return ExitPartialFailure
+	case downloader.StatusTotalFailure:
+		return ExitTotalFailure
+	case downloader.StatusInterrupted:
+		return ExitInterrupted
+	}
+	return ExitSuccess
+}
+
+// checkExitCode maps the invalid items found by the validate and verify
+// commands out of total to the process exit code.
+func checkExitCode(invalid int, total int) int {
+	switch {
+	case invalid == 0:
+		return ExitSuccess
+	case invalid == total:
+		return ExitTotalFailure
+	}
+	return ExitPartialFailure
+}
@@ -0,0 +1,22 @@
+package src
+
+import (
+	"testing"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// Test the exit code of every run status
+func TestExitCode(t *testing.T) {
+	tests := map[downloader.Status]int{
+		downloader.StatusSuccess:        ExitSuccess,
+		downloader.StatusPartialFailure: ExitPartialFailure,
+		downloader.StatusTotalFailure:   ExitTotalFailure,
+		downloader.StatusInterrupted:    ExitInterrupted,
+	}
+	for status, expected := range tests {
+		if code := ExitCode(downloader.Result{Status: status}); code != expected {
+			t.Errorf("%s: expected exit code %d, got %d", status, expected, code)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package src
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// commandHook runs a shell command for every persisted file.
+//
+// Notes:
+// - The command gets URL_DOWNLOADER_URL, URL_DOWNLOADER_PATH, URL_DOWNLOADER_CONTENT_TYPE and URL_DOWNLOADER_BYTES in its environment.
+// - It runs in Stage 3, the next file is saved once the command has exited.
+// - Its output goes to the console, a failing command is logged and does not fail the download.
+type commandHook struct {
+	downloader.NoHooks
+	command string
+}
+
+func (h *commandHook) OnPersisted(download downloader.Download, path string) {
+	cmd := exec.Command("sh", "-c", h.command)
+	cmd.Env = append(os.Environ(),
+		"URL_DOWNLOADER_URL="+download.URL,
+		"URL_DOWNLOADER_PATH="+path,
+		"URL_DOWNLOADER_CONTENT_TYPE="+download.ContentType,
+		"URL_DOWNLOADER_BYTES="+strconv.Itoa(len(download.Content)),
+	)
+	cmd.Stdout = os.Stderr // stdout belongs to the progress display
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		zlog.Error().Msgf("Error running --on-persisted command for URL: %s: %v", download.URL, err)
+	}
+}
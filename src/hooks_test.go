@@ -0,0 +1,25 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// Test the command gets the URL and the path in its environment
+func TestCommandHook_OnPersisted(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	hook := &commandHook{command: `printf '%s %s' "$URL_DOWNLOADER_URL" "$URL_DOWNLOADER_PATH" > ` + out}
+
+	hook.OnPersisted(downloader.Download{URL: "www.a.com", Content: []byte("data")}, "/tmp/a.txt")
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Expected the command to run: %v", err)
+	}
+	if string(data) != "www.a.com /tmp/a.txt" {
+		t.Errorf("Unexpected command output %q", data)
+	}
+}
@@ -0,0 +1,484 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// Status of a job, see jobStatus. A finished job has the downloader.Status of its run instead.
+const (
+	JOB_QUEUED   = "queued" // waiting for one of the --max-jobs slots
+	JOB_RUNNING  = "running"
+	JOB_CANCELED = "canceled" // canceled with DELETE /jobs/{id}
+)
+
+// jobServer runs the download jobs submitted to the serve command.
+type jobServer struct {
+	ctx     context.Context // canceled on shutdown, interrupts every job
+	dir     string
+	token   string        // bearer token required by the API, empty accepts every client
+	timeout time.Duration // deadline of the jobs that don't set their own
+	options downloader.Options
+	pool    *downloader.WorkerPool // shared by the downloads of every job
+	slots   chan struct{}          // taken by every running job, see --max-jobs
+	wg      sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// newJobServer returns a server running maxJobs jobs at once in dir, whose
+// downloads share a pool of options.Workers workers.
+func newJobServer(ctx context.Context, dir string, timeout time.Duration, options downloader.Options, maxJobs int) *jobServer {
+	return &jobServer{
+		ctx:     ctx,
+		dir:     dir,
+		timeout: timeout,
+		options: options,
+		pool:    downloader.NewWorkerPool(options.Workers),
+		slots:   make(chan struct{}, maxJobs),
+		jobs:    make(map[string]*job),
+	}
+}
+
+// job is a download run submitted over HTTP, saved in <jobs-dir>/<job id>.
+type job struct {
+	dir     string
+	ctx     context.Context // canceled by DELETE /jobs/{id} or on shutdown
+	cancel  context.CancelFunc
+	metrics *downloader.Metrics
+	done    chan struct{} // closed once the job has stopped
+
+	mu       sync.Mutex
+	record   jobStatus // status saved in job.json, the counters are only set once finished
+	canceled bool
+}
+
+// jobStatus is the JSON representation of a job, saved as <jobs-dir>/<job id>/job.json.
+type jobStatus struct {
+	ID        string      `json:"id"`
+	Status    string      `json:"status"` // JOB_QUEUED, JOB_RUNNING, JOB_CANCELED or the downloader.Status of the finished run
+	Input     string      `json:"input"`  // input file of the job, in its directory
+	Options   jobOptions  `json:"options"`
+	URLs      int         `json:"urls"` // URLs or rows submitted
+	Successes uint64      `json:"successes"`
+	Failures  uint64      `json:"failures"`
+	Skipped   uint64      `json:"skipped"`
+	Metrics   *jobMetrics `json:"metrics,omitempty"` // live while running, final once finished
+	Created   time.Time   `json:"created"`
+	Started   *time.Time  `json:"started,omitempty"`
+	Finished  *time.Time  `json:"finished,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// jobMetrics is a snapshot of the Metrics of a job.
+type jobMetrics struct {
+	Read           uint64                 `json:"read"` // URLs read from the input
+	InFlight       int64                  `json:"in_flight"`
+	Blocked        uint64                 `json:"blocked"`
+	Bytes          uint64                 `json:"bytes"`
+	FailureClasses map[string]uint64      `json:"failure_classes"`
+	LatencyMs      downloader.Percentiles `json:"latency_ms"`
+	Queues         map[string]int         `json:"queues"`
+}
+
+// jobOptions are the options a job sets for itself, named like the command
+// line flags; the other options are those of the serve command.
+type jobOptions struct {
+	Timeout         string   `json:"timeout,omitempty"` // deadline of the job, e.g. "10m"
+	DefaultScheme   string   `json:"default-scheme,omitempty"`
+	StripTracking   bool     `json:"strip-tracking,omitempty"`
+	MaxSize         int64    `json:"max-size,omitempty"`
+	AllowTypes      []string `json:"allow-type,omitempty"`
+	DenyTypes       []string `json:"deny-type,omitempty"`
+	UserAgent       string   `json:"user-agent,omitempty"`
+	Headers         []string `json:"header,omitempty"` // added to the headers of the serve command
+	RespectRobots   bool     `json:"robots,omitempty"`
+	MaxFailureRatio float64  `json:"max-failure-ratio,omitempty"`
+}
+
+// apply returns the download options and the deadline of a job from those of the server.
+//
+// Notes:
+// - Without base.AuthHosts, the server's Basic credentials, bearer token and netrc default entry are not sent by jobs, since any API client chooses their URLs; netrc entries naming a host are kept.
+func (o jobOptions) apply(base downloader.Options, timeout time.Duration) (downloader.Options, time.Duration, error) {
+	opts := base
+	if len(base.AuthHosts) == 0 {
+		opts.BasicAuth, opts.BearerToken = nil, ""
+		if _, ok := base.Netrc[""]; ok {
+			opts.Netrc = maps.Clone(base.Netrc)
+			delete(opts.Netrc, "")
+		}
+	}
+	if o.Timeout != "" {
+		duration, err := time.ParseDuration(o.Timeout)
+		if err != nil || duration <= 0 {
+			return opts, 0, fmt.Errorf("timeout: invalid duration %q", o.Timeout)
+		}
+		timeout = duration
+	}
+	if o.DefaultScheme != "" {
+		opts.URLs.DefaultScheme = o.DefaultScheme
+	}
+	opts.URLs.StripTracking = opts.URLs.StripTracking || o.StripTracking
+	if o.MaxSize > 0 {
+		opts.MaxBodySize = o.MaxSize
+	}
+	if len(o.AllowTypes) > 0 {
+		opts.AllowTypes = o.AllowTypes
+	}
+	if len(o.DenyTypes) > 0 {
+		opts.DenyTypes = o.DenyTypes
+	}
+	if o.UserAgent != "" {
+		opts.UserAgent = o.UserAgent
+	}
+	for _, header := range o.Headers {
+		if name, _, ok := strings.Cut(header, ":"); !ok || strings.TrimSpace(name) == "" {
+			return opts, 0, fmt.Errorf("header: %q must be in the form \"Name: value\"", header)
+		}
+	}
+	opts.Headers = append(append([]string{}, base.Headers...), o.Headers...)
+	opts.RespectRobots = opts.RespectRobots || o.RespectRobots
+	if o.MaxFailureRatio > 0 {
+		opts.MaxFailureRatio = o.MaxFailureRatio
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, 0, err
+	}
+	return opts, timeout, nil
+}
+
+// submit creates a job whose input is written by writeInput into the job
+// directory, saves it and queues it.
+//
+// Input:
+// - options: The options of the job, already checked.
+// - writeInput: Writes the input file into the job directory and returns its name and number of URLs.
+func (s *jobServer) submit(options jobOptions, writeInput func(dir string) (string, int, error)) (*job, error) {
+	if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	// IDs are time based; the directory of a job submitted within the same
+	// nanosecond already exists, the next ID is tried then
+	var id, dir string
+	for tick := time.Now().UnixNano(); ; tick++ {
+		id = strconv.FormatInt(tick, 36)
+		dir = filepath.Join(s.dir, id)
+		err := os.Mkdir(dir, os.ModePerm)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+	}
+	input, urls, err := writeInput(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	j := s.newJob(jobStatus{ID: id, Status: JOB_QUEUED, Input: input, Options: options, URLs: urls, Created: time.Now()})
+	if err := j.save(); err != nil {
+		s.remove(j)
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	zlog.Info().Msgf("Job %s: queued %d URLs into %s", id, urls, dir)
+	s.start(j)
+	return j, nil
+}
+
+// writeURLs writes urls as the JSON Lines input of a job.
+func writeURLs(dir string, urls []string) (string, int, error) {
+	file, err := os.Create(filepath.Join(dir, "input.jsonl"))
+	if err != nil {
+		return "", 0, err
+	}
+	encoder := json.NewEncoder(file)
+	for _, url := range urls {
+		if err := encoder.Encode(struct {
+			URL string `json:"url"`
+		}{url}); err != nil {
+			file.Close()
+			return "", 0, err
+		}
+	}
+	return "input.jsonl", len(urls), file.Close()
+}
+
+// writeUpload writes the uploaded csv or jsonl file (ext) as the input of a job.
+func writeUpload(dir string, upload io.Reader, ext string) (string, int, error) {
+	name := "input." + ext
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := io.Copy(file, upload); err != nil {
+		file.Close()
+		return "", 0, err
+	}
+	if err := file.Close(); err != nil {
+		return "", 0, err
+	}
+	rows, err := downloader.CountCSVRows(filepath.Join(dir, name))
+	return name, int(rows), err
+}
+
+// restore loads the jobs saved in the jobs directory. Finished jobs keep their
+// status; the queued and running ones, interrupted by the previous shutdown,
+// are queued again, oldest first.
+//
+// Notes:
+// - A job queued again runs from the start: its manifest keeps the entries of the interrupted run, see downloader.LatestEntries.
+// - Unreadable job.json files are logged and skipped.
+func (s *jobServer) restore() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*", "job.json"))
+	if err != nil {
+		return err
+	}
+	var records []jobStatus
+	for _, path := range paths {
+		var record jobStatus
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &record)
+		}
+		if err != nil || record.ID != filepath.Base(filepath.Dir(path)) {
+			zlog.Warn().Msgf("Skipping job %s: invalid job file: %v", path, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, k int) bool { return records[i].Created.Before(records[k].Created) })
+
+	queued := 0
+	for _, record := range records {
+		if record.Status != JOB_QUEUED && record.Status != JOB_RUNNING {
+			j := s.newJob(record)
+			j.cancel()
+			close(j.done)
+			continue
+		}
+		record.Status, record.Started = JOB_QUEUED, nil
+		j := s.newJob(record)
+		if err := j.save(); err != nil {
+			return err
+		}
+		s.start(j)
+		queued++
+	}
+	if len(records) > 0 {
+		zlog.Info().Msgf("Restored %d jobs from %s, %d queued again", len(records), s.dir, queued)
+	}
+	return nil
+}
+
+// newJob registers the job of record.
+func (s *jobServer) newJob(record jobStatus) *job {
+	ctx, cancel := context.WithCancel(s.ctx)
+	j := &job{
+		dir:     filepath.Join(s.dir, record.ID),
+		ctx:     ctx,
+		cancel:  cancel,
+		metrics: &downloader.Metrics{},
+		done:    make(chan struct{}),
+		record:  record,
+	}
+	s.mu.Lock()
+	s.jobs[record.ID] = j
+	s.mu.Unlock()
+	return j
+}
+
+// remove unregisters a job that could not be saved.
+func (s *jobServer) remove(j *job) {
+	s.mu.Lock()
+	delete(s.jobs, j.record.ID)
+	s.mu.Unlock()
+	j.cancel()
+}
+
+// statuses returns the status of every job, oldest first.
+func (s *jobServer) statuses() []jobStatus {
+	s.mu.Lock()
+	statuses := make([]jobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status())
+	}
+	s.mu.Unlock()
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Created.Before(statuses[k].Created) })
+	return statuses
+}
+
+// start runs the job in the background once one of the --max-jobs slots is free.
+func (s *jobServer) start(j *job) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(j.done)
+		defer j.cancel()
+
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-j.ctx.Done():
+			s.finish(j, downloader.Result{Status: downloader.StatusInterrupted}, nil)
+			return
+		}
+
+		j.mu.Lock()
+		started := time.Now()
+		j.record.Status, j.record.Started = JOB_RUNNING, &started
+		id := j.record.ID
+		j.mu.Unlock()
+		if err := j.save(); err != nil {
+			zlog.Error().Msgf("Job %s: error saving the job: %v", id, err)
+		}
+		zlog.Info().Msgf("Job %s: started", id)
+
+		result, err := s.download(j)
+		s.finish(j, result, err)
+	}()
+}
+
+// download runs the job through the pipeline, writing its downloads, manifest
+// and rejected rows into its directory.
+func (s *jobServer) download(j *job) (downloader.Result, error) {
+	j.mu.Lock()
+	record := j.record
+	j.mu.Unlock()
+	failed := downloader.Result{Status: downloader.StatusTotalFailure}
+
+	opts, timeout, err := record.Options.apply(s.options, s.timeout)
+	if err != nil {
+		return failed, err
+	}
+	sink, err := downloader.NewDirSink(filepath.Join(j.dir, "downloads"))
+	if err != nil {
+		return failed, err
+	}
+	manifest, err := downloader.OpenManifest(filepath.Join(j.dir, "manifest.jsonl"))
+	if err != nil {
+		return failed, err
+	}
+	defer manifest.Close()
+	rejects, err := downloader.OpenRejectLog(filepath.Join(j.dir, "rejects.csv"))
+	if err != nil {
+		return failed, err
+	}
+	defer rejects.Close()
+
+	opts.Logger = zlog.With().Str("job", record.ID).Logger()
+	opts.Metrics = j.metrics
+	opts.Manifest = manifest
+	opts.Rejects = rejects
+	opts.Pool = s.pool
+	d, err := downloader.New(opts)
+	if err != nil {
+		return failed, err
+	}
+	ctx, cancel := context.WithTimeout(j.ctx, timeout)
+	defer cancel()
+	return d.Run(ctx, downloader.FileSource(filepath.Join(j.dir, record.Input)), sink)
+}
+
+// finish records the outcome of the job and saves it. A job interrupted by the
+// shutdown keeps its saved status, so that it runs again on the next start.
+func (s *jobServer) finish(j *job, result downloader.Result, err error) {
+	j.mu.Lock()
+	id := j.record.ID
+	interrupted := result.Status == downloader.StatusInterrupted
+	if interrupted && !j.canceled && s.ctx.Err() != nil {
+		j.mu.Unlock()
+		zlog.Info().Msgf("Job %s: interrupted by the shutdown, it runs again on the next start", id)
+		return
+	}
+	finished := time.Now()
+	j.count(&j.record)
+	j.record.Status, j.record.Finished = result.Status.String(), &finished
+	if interrupted && j.canceled {
+		j.record.Status = JOB_CANCELED
+	}
+	if err != nil {
+		j.record.Error = err.Error()
+	}
+	status := j.record.Status
+	j.mu.Unlock()
+
+	if err := j.save(); err != nil {
+		zlog.Error().Msgf("Job %s: error saving the job: %v", id, err)
+	}
+	zlog.Info().Msgf("Job %s: %s", id, status)
+}
+
+// stop cancels the job, false if it already finished.
+func (j *job) stop() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+	}
+	j.mu.Lock()
+	j.canceled = true
+	j.mu.Unlock()
+	j.cancel()
+	return true
+}
+
+// status returns the status of the job, with the live counters of a running job.
+func (j *job) status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.record
+	if status.Status == JOB_RUNNING {
+		j.count(&status)
+	}
+	return status
+}
+
+// count sets the counters and metrics of status from the metrics of the job.
+func (j *job) count(status *jobStatus) {
+	m := j.metrics
+	status.Successes = m.SuccessCount.Load()
+	status.Failures = m.FailureCount.Load()
+	status.Skipped = m.SkippedCount.Load()
+	status.Metrics = &jobMetrics{
+		Read:           m.TotalURLs.Load(),
+		InFlight:       m.InFlight.Load(),
+		Blocked:        m.BlockedCount.Load(),
+		Bytes:          m.TotalBytes.Load(),
+		FailureClasses: m.FailureCounts(),
+		LatencyMs:      downloader.HistogramPercentiles(&m.Latency),
+		Queues:         m.QueueDepths(),
+	}
+}
+
+// save writes the status of the job to job.json through a temporary file, so
+// that a crash never leaves it truncated.
+func (j *job) save() error {
+	data, err := json.MarshalIndent(j.status(), "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(j.dir, "job.json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
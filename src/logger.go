@@ -11,9 +11,11 @@ import (
 )
 
 // Helpful guide: https://betterstack.com/community/guides/logging/zerolog/
-func initLogger(filePath string) (err error, logger zerolog.Logger) {
+// With console false the log only goes to the file, e.g. while the tty progress display owns the terminal.
+// Events below level are dropped.
+func initLogger(filePath string, console bool, level zerolog.Level) (err error, logger zerolog.Logger) {
 	// Open the log file for writing
-	logPath := strings.Split(filePath, ".")[0]
+	logPath := getOutputBase(filePath)
 	err = os.MkdirAll(logPath, os.ModePerm)
 	if err != nil {
 		return err, logger
@@ -41,6 +43,9 @@ func initLogger(filePath string) (err error, logger zerolog.Logger) {
 	// Create a console output writer
 	consoleWriter := zerolog.ConsoleWriter{Out: os.Stdout}
 	consoleWriter.TimeFormat = zerolog.TimeFieldFormat
-	logger = zerolog.New(zerolog.MultiLevelWriter(consoleWriter, file)).With().Timestamp().Logger()
+	if !console {
+		return nil, zerolog.New(file).Level(level).With().Timestamp().Logger()
+	}
+	logger = zerolog.New(zerolog.MultiLevelWriter(consoleWriter, file)).Level(level).With().Timestamp().Logger()
 	return nil, logger
 }
@@ -1,39 +1,41 @@
 package src
 
 import (
-	"log"
-	"sync/atomic"
+	"context"
+	"errors"
+	"net"
+	"net/http"
 	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
 )
 
-// Metrics tracks the progress of URL processing
-type Metrics struct {
-	TotalURLs     atomic.Uint64 // Total number of URLs processed
-	SuccessCount  atomic.Uint64 // Number of successful downloads
-	FailureCount  atomic.Uint64 // Number of failed downloads
-	TotalDuration atomic.Uint64 // Total duration of all successful downloads (in nanoseconds)
-	PrcStartTime  time.Time
-	PrcEndTime    time.Time
-}
+// startMetricsServer serves the Prometheus text format of metrics on addr at /metrics.
+//
+// Output:
+// - Returns a function that shuts the server down, waiting at most SHUTDOWN_DEAD_LINE.
+// - Returns an error if addr cannot be listened on.
+//
+// Notes:
+// - The queue depths of the pipeline channels are read on every scrape.
+func startMetricsServer(addr string, m *downloader.Metrics) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
 
-func (m *Metrics) AddSuccess(duration time.Duration) {
-	m.SuccessCount.Add(1)
-	m.TotalDuration.Add(uint64(duration.Nanoseconds()))
-}
+	server := &http.Server{Handler: downloader.MetricsHandler(m), ReadHeaderTimeout: 10 * time.Second}
 
-func (m *Metrics) AddFailure() {
-	m.FailureCount.Add(1)
-}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zlog.Error().Msgf("Metrics server stopped: %v", err)
+		}
+	}()
+	zlog.Info().Msgf("Serving Prometheus metrics on http://%s/metrics", listener.Addr())
 
-func (m *Metrics) LogSummary() {
-	totalURLs := m.TotalURLs.Load()
-	successCount := m.SuccessCount.Load()
-	failureCount := m.FailureCount.Load()
-	totalDuration := time.Duration(m.TotalDuration.Load())
-	avgDuration := time.Duration(0)
-	if successCount > 0 {
-		avgDuration = totalDuration / time.Duration(successCount)
-	}
-	log.Printf("Summary: Total URLs=%d, Success=%d, Failures=%d, Avg Download Duration=%v", totalURLs, successCount, failureCount, avgDuration)
-	zlog.Info().Uint64("Total URLs", totalURLs).Uint64("Success", successCount).Uint64("Failures", failureCount).Str("Avg Download Duration", avgDuration.String()).Str("Latency", m.PrcEndTime.Sub(m.PrcStartTime).String()).Msg("Summary")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_DEAD_LINE)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
 }
@@ -0,0 +1,14 @@
+package src
+
+import (
+	"testing"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// Test the server rejects invalid addresses
+func TestStartMetricsServer_InvalidAddress(t *testing.T) {
+	if _, err := startMetricsServer("invalid-address", &downloader.Metrics{}); err == nil {
+		t.Errorf("Expected error for an invalid address, but got nil")
+	}
+}
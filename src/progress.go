@@ -0,0 +1,162 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// Progress display modes for --progress.
+const (
+	ProgressAuto  = "auto"  // tty when stdout is a terminal, plain otherwise
+	ProgressTTY   = "tty"   // redraw a multi line block in place
+	ProgressPlain = "plain" // log a single progress line periodically
+	ProgressOff   = "off"
+
+	PROGRESS_REFRESH   = 250 * time.Millisecond // tty redraw interval
+	PROGRESS_TRANSFERS = 10                     // in-flight downloads shown in tty mode
+)
+
+// resolveProgressMode turns ProgressAuto into ProgressTTY or ProgressPlain.
+func resolveProgressMode(mode string) string {
+	if mode != ProgressAuto {
+		return mode
+	}
+	if isTerminal(os.Stdout) {
+		return ProgressTTY
+	}
+	return ProgressPlain
+}
+
+// isTerminal reports whether file is a character device such as a terminal.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startProgress reports the progress of m until the returned function is called.
+//
+// Input:
+// - m: Metrics of the run; m.ExpectedURLs is used for the percentage and the ETA.
+// - mode: ProgressTTY or ProgressPlain, see resolveProgressMode.
+// - interval: how often ProgressPlain logs a line.
+// - out: where ProgressTTY draws, usually os.Stdout.
+//
+// Notes:
+// - The stop function draws the final state and waits for the reporter to exit.
+func startProgress(m *downloader.Metrics, mode string, interval time.Duration, out io.Writer) func() {
+	if mode != ProgressTTY && mode != ProgressPlain {
+		return func() {}
+	}
+	if mode == ProgressTTY {
+		interval = PROGRESS_REFRESH
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lines := 0
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				if mode == ProgressTTY {
+					renderProgress(out, m, lines, nil)
+				}
+				return
+			}
+			if mode == ProgressTTY {
+				lines = renderProgress(out, m, lines, m.Transfers())
+			} else {
+				zlog.Info().Msg(progressLine(m))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// renderProgress redraws the progress block over the previous one, which was
+// previousLines high, listing the active transfers below the progress line.
+// It returns the number of lines drawn.
+func renderProgress(out io.Writer, m *downloader.Metrics, previousLines int, active []downloader.Transfer) int {
+	var b strings.Builder
+	if previousLines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", previousLines) // move the cursor up to the previous block
+	}
+	b.WriteString("\x1b[J") // clear to the end of the screen
+	b.WriteString(progressLine(m))
+	b.WriteByte('\n')
+	lines := 1
+
+	for i, t := range active {
+		if i == PROGRESS_TRANSFERS {
+			fmt.Fprintf(&b, "  ... %d more\n", len(active)-i)
+			lines++
+			break
+		}
+		if t.Total > 0 {
+			fmt.Fprintf(&b, "  [%3d%%] %s  %s / %s\n", t.Read*100/t.Total, truncate(t.URL, 60), formatBytes(t.Read), formatBytes(t.Total))
+		} else {
+			fmt.Fprintf(&b, "  [ ?? ] %s  %s\n", truncate(t.URL, 60), formatBytes(t.Read))
+		}
+		lines++
+	}
+	io.WriteString(out, b.String())
+	return lines
+}
+
+// progressLine summarizes the counts, throughput and ETA in a single line.
+func progressLine(m *downloader.Metrics) string {
+	success, failed := m.SuccessCount.Load(), m.FailureCount.Load()
+	skipped := m.SkippedCount.Load() + m.BlockedCount.Load()
+	completed := success + failed + skipped
+	mbPerSec, urlsPerSec := m.Throughput()
+
+	total := m.ExpectedURLs.Load()
+	if read := m.TotalURLs.Load(); read > total {
+		total = read
+	}
+	percent, eta := 0.0, "?"
+	if total > 0 {
+		percent = float64(completed) * 100 / float64(total)
+	}
+	if urlsPerSec > 0 && total >= completed {
+		eta = time.Duration(float64(total-completed) / urlsPerSec * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("Progress: %d/%d (%.1f%%) ok=%d failed=%d skipped=%d in-flight=%d | %.2f MB/s %.1f URLs/s | ETA %s",
+		completed, total, percent, success, failed, skipped, m.InFlight.Load(), mbPerSec, urlsPerSec, eta)
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// truncate shortens s to at most n characters, keeping its end.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n+3:]
+}
@@ -0,0 +1,38 @@
+package src
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// Test the progress line uses the expected row count
+func TestProgressLine(t *testing.T) {
+	m := &downloader.Metrics{PrcStartTime: time.Now().Add(-10 * time.Second)}
+	m.ExpectedURLs.Store(10)
+	m.AddSuccess(time.Second, time.Millisecond, 1024)
+	m.AddFailure(downloader.FailureDNS)
+
+	line := progressLine(m)
+	for _, expected := range []string{"Progress: 2/10 (20.0%)", "ok=1", "failed=1", "ETA "} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("Expected %q in progress line %q", expected, line)
+		}
+	}
+}
+
+// Test the tty block shows in-flight transfers with their progress
+func TestRenderProgress_Transfers(t *testing.T) {
+	active := []downloader.Transfer{{URL: "https://example.com/file.bin", Total: 2048, Read: 1024, Started: time.Now()}}
+
+	var out strings.Builder
+	lines := renderProgress(&out, &downloader.Metrics{PrcStartTime: time.Now()}, 0, active)
+	if lines != 2 {
+		t.Errorf("Expected 2 lines, got %d", lines)
+	}
+	if !strings.Contains(out.String(), "[ 50%] https://example.com/file.bin  1.0 KiB / 2.0 KiB") {
+		t.Errorf("Unexpected progress block:\n%s", out.String())
+	}
+}
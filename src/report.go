@@ -0,0 +1,90 @@
+package src
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// reportFlags registers the flags of the report command.
+func reportFlags(fs *flag.FlagSet) func(args []string) error {
+	fs.BoolVar(&jsonOutput, "json", false, "Print the summary as JSON")
+	return manifestArg("report")
+}
+
+// manifestArg returns the configuration of a command taking a manifest as its only argument.
+func manifestArg(action string) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected the manifest to %s", action)
+		}
+		manifestFile = args[0]
+		if !fileExists(manifestFile) {
+			return fmt.Errorf("manifest is not found :%s", manifestFile)
+		}
+		return nil
+	}
+}
+
+// runReport prints the summary of manifestFile.
+func runReport() int {
+	entries, err := downloader.ReadManifest(manifestFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+	summary := downloader.SummarizeManifest(entries)
+
+	if jsonOutput {
+		data, _ := json.MarshalIndent(summary, "", "  ")
+		fmt.Println(string(data))
+		return ExitSuccess
+	}
+	printSummary(os.Stdout, manifestFile, summary)
+	return ExitSuccess
+}
+
+// printSummary prints a manifest summary as text.
+func printSummary(out io.Writer, filePath string, summary downloader.ManifestSummary) {
+	fmt.Fprintf(out, "Manifest: %s\n", filePath)
+	fmt.Fprintf(out, "URLs: %d from %d hosts (%d entries)\n", summary.URLs, summary.Hosts, summary.Entries)
+	if summary.Entries > 0 {
+		fmt.Fprintf(out, "Recorded: %s to %s\n", summary.FirstTime.Format(time.RFC3339), summary.LastTime.Format(time.RFC3339))
+	}
+	fmt.Fprintf(out, "Bytes: %d\n", summary.Bytes)
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Outcomes:")
+	for _, outcome := range sortedCounts(summary.Outcomes) {
+		fmt.Fprintf(w, "\t%s\t%d\n", outcome, summary.Outcomes[outcome])
+	}
+	if len(summary.FailureClasses) > 0 {
+		fmt.Fprintln(w, "Failure classes:")
+		for _, class := range sortedCounts(summary.FailureClasses) {
+			fmt.Fprintf(w, "\t%s\t%d\n", class, summary.FailureClasses[class])
+		}
+	}
+	w.Flush()
+}
+
+// sortedCounts returns the keys of counts, largest count first.
+func sortedCounts(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,35 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// retryInputPath returns the input file of the run that wrote filePath, so that
+// the retry shares its output directory, log and manifest.
+func retryInputPath(filePath string) (string, error) {
+	if GetFileExtension(filePath) == "jsonl" {
+		// The manifest lives in <input_without_extension>/manifest.jsonl
+		input := filepath.Dir(filePath) + ".csv"
+		if jsonl := filepath.Dir(filePath) + ".jsonl"; !fileExists(input) && fileExists(jsonl) {
+			input = jsonl
+		}
+		return input, nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	var report downloader.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return "", fmt.Errorf("%s: invalid report: %v", filePath, err)
+	}
+	if report.Input == "" {
+		return "", fmt.Errorf("%s: report has no input file", filePath)
+	}
+	return report.Input, nil
+}
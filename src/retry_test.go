@@ -0,0 +1,23 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test a retry writes into the output directory of the run it retries
+func TestRetryInputPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "list")
+	if input, _ := retryInputPath(filepath.Join(dir, "manifest.jsonl")); getOutputBase(input) != dir {
+		t.Errorf("Expected the retry to write into %s, got %s", dir, getOutputBase(input))
+	}
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(reportFile, []byte(`{"input":"/data/list.csv","failed_urls":[]}`), 0644); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	if input, _ := retryInputPath(reportFile); input != "/data/list.csv" {
+		t.Errorf("Expected input /data/list.csv, got %s", input)
+	}
+}
@@ -0,0 +1,266 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+	"github.com/rs/zerolog"
+)
+
+const MAX_UPLOAD = 64 << 20 // maximum size of a job request, e.g. an uploaded csv file
+
+// serveFlags registers the flags of the serve command.
+func serveFlags(fs *flag.FlagSet) func(args []string) error {
+	fs.StringVar(&configFile, "config", os.Getenv(ENV_PREFIX+"CONFIG"), "YAML, TOML or JSON `file` setting any option by its long name (e.g. max-size: 1048576)")
+	fs.StringVar(&serveAddr, "addr", "localhost:8080", "Listen `addr` of the HTTP API, e.g. :8080 for every interface")
+	tokenFile := fs.String("api-token-file", "", "Require the bearer token read from `file` from every API client")
+	tokenEnv := fs.String("api-token-env", "", "Require the bearer token read from the environment `variable` from every API client")
+	fs.BoolVar(&allowPrivate, "allow-private", false, "Let jobs download localhost and private addresses, rejected by default")
+	fs.StringVar(&jobsDir, "jobs-dir", "jobs", "Write every job into `dir`/<job id>")
+	fs.IntVar(&maxJobs, "max-jobs", 4, "Run at most `n` jobs at once, the other ones wait in a queue")
+	fs.DurationVar(&runTimeout, "timeout", time.Hour, "Deadline of every job")
+	fs.StringVar(&logLevel, "log-level", zerolog.LevelDebugValue, "Log `level`: debug, info, warn or error")
+	configureOptions := optionFlags(fs)
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected argument %q", args[0])
+		}
+		if err := configureOptions(); err != nil {
+			return err
+		}
+		if maxJobs < 1 {
+			return invalidOption("max-jobs", "must be at least 1")
+		}
+		var err error
+		if apiToken, err = downloader.LoadBearerToken(*tokenFile, *tokenEnv); err != nil {
+			return err
+		}
+		// Jobs download the URLs of any API client, keep them off the internal network
+		options.URLs.RejectPrivate = options.URLs.RejectPrivate || !allowPrivate
+		return validateOptions()
+	}
+}
+
+// runServe serves the job API until SIGINT/SIGTERM is received.
+//
+// Notes:
+// - POST /jobs starts a job: a JSON body {"urls": [...], "options": {...}}, or a multipart/form-data upload of a csv or jsonl "file" with an optional JSON "options" field.
+// - GET /jobs lists the jobs, GET /jobs/{id} returns the status of a job with its live metrics, GET /jobs/{id}/manifest its manifest and DELETE /jobs/{id} cancels it.
+// - At most --max-jobs jobs run at once and their downloads share a pool of --workers workers.
+// - The API listens on localhost unless --addr says otherwise; with --api-token-file/--api-token-env every request must send the token as a bearer token.
+// - Jobs reject private addresses unless --allow-private is set, and only send the server credentials to the --auth-host hosts, see jobOptions.apply.
+// - Jobs are saved in <jobs-dir>/<job id>; the jobs queued or running on shutdown are interrupted and run again on the next start.
+// - The log goes to <jobs-dir>/<jobs-dir name>.log, like the log of a csv file.
+func runServe() int {
+	var err error
+	level, _ := zerolog.ParseLevel(logLevel) // validated by validateOptions
+	if err, zlog = initLogger(filepath.Clean(jobsDir)+".log", true, level); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", serveAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+	jobs := newJobServer(ctx, jobsDir, runTimeout, options, maxJobs)
+	jobs.token = apiToken
+	if apiToken == "" && !isLoopback(listener.Addr()) {
+		zlog.Warn().Msgf("The job API on %s accepts jobs from any client, set --api-token-file or --api-token-env", listener.Addr())
+	}
+	if err := jobs.restore(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+	server := &http.Server{Handler: jobs.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zlog.Error().Msgf("Job API stopped: %v", err)
+			stop()
+		}
+	}()
+	zlog.Info().Msgf("Serving the job API on http://%s/jobs", listener.Addr())
+
+	<-ctx.Done()
+	zlog.Info().Msg("Shutdown initiated. Stopping the job API")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_DEAD_LINE)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	jobs.wg.Wait()
+	return ExitSuccess
+}
+
+// handler returns the routes of the job API.
+func (s *jobServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleStatus)
+	mux.HandleFunc("GET /jobs/{id}/manifest", s.handleManifest)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancel)
+	if s.token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// isLoopback reports whether addr only accepts local connections.
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// handleSubmit queues a job downloading the URLs or the file of the request body.
+func (s *jobServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_UPLOAD)
+	var options jobOptions
+	var writeInput func(dir string) (string, int, error)
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid job: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		ext := strings.ToLower(GetFileExtension(header.Filename)) // LIST.CSV is a csv file
+		if ext != "csv" && ext != "jsonl" {
+			http.Error(w, "invalid job: expected a csv or jsonl file", http.StatusBadRequest)
+			return
+		}
+		if value := r.FormValue("options"); value != "" {
+			if err := decodeStrict([]byte(value), &options); err != nil {
+				http.Error(w, fmt.Sprintf("invalid job options: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		writeInput = func(dir string) (string, int, error) { return writeUpload(dir, file, ext) }
+	} else {
+		var request struct {
+			URLs    []string   `json:"urls"`
+			Options jobOptions `json:"options"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("invalid job: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(request.URLs) == 0 {
+			http.Error(w, "invalid job: no urls", http.StatusBadRequest)
+			return
+		}
+		options = request.Options
+		writeInput = func(dir string) (string, int, error) { return writeURLs(dir, request.URLs) }
+	}
+	if _, _, err := options.apply(s.options, s.timeout); err != nil {
+		http.Error(w, fmt.Sprintf("invalid job options: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	j, err := s.submit(options, writeInput)
+	if err != nil {
+		zlog.Error().Msgf("Error starting job: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j.status())
+}
+
+// handleList returns the status of every job, oldest first.
+func (s *jobServer) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.statuses())
+}
+
+// handleStatus returns the status of a job.
+func (s *jobServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if j, ok := s.lookup(w, r); ok {
+		writeJSON(w, http.StatusOK, j.status())
+	}
+}
+
+// handleManifest returns the manifest of a job, as written so far.
+func (s *jobServer) handleManifest(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	path := filepath.Join(j.dir, "manifest.jsonl")
+	if !fileExists(path) {
+		http.Error(w, "job has no manifest yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	http.ServeFile(w, r, path)
+}
+
+// handleCancel cancels a queued or running job and returns its status once it stopped.
+func (s *jobServer) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if !j.stop() {
+		http.Error(w, "job already finished", http.StatusConflict)
+		return
+	}
+	select {
+	case <-j.done:
+	case <-r.Context().Done():
+		return
+	}
+	writeJSON(w, http.StatusOK, j.status())
+}
+
+// lookup returns the job of the request path, writing a 404 response if there is none.
+func (s *jobServer) lookup(w http.ResponseWriter, r *http.Request) (*job, bool) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+	}
+	return j, ok
+}
+
+// decodeStrict decodes the JSON data into value, rejecting unknown fields.
+func decodeStrict(data []byte, value any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(value)
+}
+
+// writeJSON writes value as the JSON response body.
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,235 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// newTestJobServer returns a job server writing into dir and its API server.
+func newTestJobServer(t *testing.T, ctx context.Context, dir string, maxJobs int) (*jobServer, *httptest.Server) {
+	t.Helper()
+	s := newJobServer(ctx, dir, time.Minute, downloader.Options{Workers: 2}, maxJobs)
+	if err := s.restore(); err != nil {
+		t.Fatalf("Failed to restore jobs: %v", err)
+	}
+	api := httptest.NewServer(s.handler())
+	t.Cleanup(api.Close)
+	return s, api
+}
+
+// submitJob posts a JSON job and returns its status.
+func submitJob(t *testing.T, api *httptest.Server, body string) jobStatus {
+	t.Helper()
+	response, err := http.Post(api.URL+"/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	defer response.Body.Close()
+	var status jobStatus
+	if response.StatusCode != http.StatusAccepted || json.NewDecoder(response.Body).Decode(&status) != nil {
+		t.Fatalf("Expected the job to be accepted, got %s", response.Status)
+	}
+	return status
+}
+
+// waitJob waits for the job id to stop and returns its status.
+func waitJob(t *testing.T, s *jobServer, id string) jobStatus {
+	t.Helper()
+	s.mu.Lock()
+	j := s.jobs[id]
+	s.mu.Unlock()
+	select {
+	case <-j.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Job %s did not finish", id)
+	}
+	return j.status()
+}
+
+// Test jobs submitted as URLs or as an uploaded csv file, with their status and manifest
+func TestServe_Jobs(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("mock data"))
+	}))
+	defer files.Close()
+	s, api := newTestJobServer(t, context.Background(), t.TempDir(), 2)
+
+	submitted := submitJob(t, api, `{"urls": ["`+files.URL+`/a", "`+files.URL+`/b"]}`)
+	status := waitJob(t, s, submitted.ID)
+	if status.Status != "success" || status.URLs != 2 || status.Successes != 2 || status.Metrics == nil || status.Metrics.Bytes != 18 {
+		t.Errorf("Expected 2 successful downloads, got %+v", status)
+	}
+	response, err := http.Get(api.URL + "/jobs/" + submitted.ID + "/manifest")
+	if err != nil {
+		t.Fatalf("Failed to get manifest: %v", err)
+	}
+	manifest := new(bytes.Buffer)
+	manifest.ReadFrom(response.Body)
+	response.Body.Close()
+	if lines := strings.Count(manifest.String(), "\n"); lines != 2 || response.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Expected 2 manifest entries, got %d (%s)", lines, response.Header.Get("Content-Type"))
+	}
+
+	// Upload a csv file, with an upper case extension, whose responses exceed the max-size of the job
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, _ := form.CreateFormFile("file", "LIST.CSV")
+	part.Write([]byte("url\n" + files.URL + "/c\n"))
+	form.WriteField("options", `{"max-size": 3}`)
+	form.Close()
+	response, err = http.Post(api.URL+"/jobs", form.FormDataContentType(), body)
+	if err != nil || response.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected the upload to be accepted, got %v (%v)", response.Status, err)
+	}
+	json.NewDecoder(response.Body).Decode(&submitted)
+	response.Body.Close()
+	if status := waitJob(t, s, submitted.ID); status.Input != "input.csv" || status.URLs != 1 || status.Skipped != 1 {
+		t.Errorf("Expected the uploaded URL to be skipped by max-size, got %+v", status)
+	}
+
+	var statuses []jobStatus
+	response, _ = http.Get(api.URL + "/jobs")
+	json.NewDecoder(response.Body).Decode(&statuses)
+	response.Body.Close()
+	if len(statuses) != 2 || statuses[1].ID != submitted.ID {
+		t.Errorf("Expected both jobs, oldest first, got %+v", statuses)
+	}
+
+	request, _ := http.NewRequest(http.MethodDelete, api.URL+"/jobs/"+submitted.ID, nil)
+	if response, _ := http.DefaultClient.Do(request); response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a finished job not to be canceled, got %s", response.Status)
+	}
+	for _, invalid := range []string{`{"urls": []}`, `{"urls": ["a"], "options": {"timeout": "soon"}}`, `{"urls": ["a"], "options": {"max-sise": 3}}`} {
+		if response, _ := http.Post(api.URL+"/jobs", "application/json", strings.NewReader(invalid)); response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %s", invalid, response.Status)
+		}
+	}
+}
+
+// Test jobs beyond max-jobs wait in the queue and jobs can be canceled
+func TestServe_Cancel(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // never answers
+	}))
+	defer files.Close()
+	s, api := newTestJobServer(t, context.Background(), t.TempDir(), 1)
+
+	first := submitJob(t, api, `{"urls": ["`+files.URL+`/a"]}`)
+	second := submitJob(t, api, `{"urls": ["`+files.URL+`/b"]}`)
+	time.Sleep(100 * time.Millisecond)
+	if status := s.jobs[second.ID].status(); status.Status != JOB_QUEUED {
+		t.Errorf("Expected the second job to wait for the first one, got %s", status.Status)
+	}
+
+	for _, id := range []string{second.ID, first.ID} {
+		request, _ := http.NewRequest(http.MethodDelete, api.URL+"/jobs/"+id, nil)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Failed to cancel job: %v", err)
+		}
+		var status jobStatus
+		json.NewDecoder(response.Body).Decode(&status)
+		response.Body.Close()
+		if status.Status != JOB_CANCELED || status.Finished == nil {
+			t.Errorf("Expected job %s to be canceled, got %+v", id, status)
+		}
+	}
+	if response, _ := http.Get(api.URL + "/jobs/unknown"); response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown job not to be found, got %s", response.Status)
+	}
+}
+
+// Test a job interrupted by the shutdown runs again on the next start
+func TestServe_Restore(t *testing.T) {
+	release := make(chan struct{})
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			w.Write([]byte("mock data"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer files.Close()
+	dir := t.TempDir()
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	s, api := newTestJobServer(t, ctx, dir, 1)
+	submitted := submitJob(t, api, `{"urls": ["`+files.URL+`/a"]}`)
+	time.Sleep(100 * time.Millisecond)
+	shutdown()
+	s.wg.Wait()
+
+	var saved jobStatus
+	data, _ := os.ReadFile(filepath.Join(dir, submitted.ID, "job.json"))
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Status != JOB_RUNNING {
+		t.Fatalf("Expected the interrupted job to be saved as running, got %+v (%v)", saved, err)
+	}
+
+	close(release)
+	s, _ = newTestJobServer(t, context.Background(), dir, 1)
+	if status := waitJob(t, s, submitted.ID); status.Status != "success" || status.Successes != 1 {
+		t.Errorf("Expected the restored job to succeed, got %+v", status)
+	}
+
+	// A finished job is restored as is
+	s, _ = newTestJobServer(t, context.Background(), dir, 1)
+	if status := waitJob(t, s, submitted.ID); status.Status != "success" || status.Successes != 1 || status.Metrics == nil {
+		t.Errorf("Expected the finished job to keep its status, got %+v", status)
+	}
+}
+
+// Test the API requires the token it is given and jobs stay off private addresses and the server credentials by default
+func TestServe_Security(t *testing.T) {
+	s := newJobServer(context.Background(), t.TempDir(), time.Minute, downloader.Options{Workers: 2}, 1)
+	s.token = "secret"
+	api := httptest.NewServer(s.handler())
+	defer api.Close()
+	for token, expected := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "secret": http.StatusOK} {
+		request, _ := http.NewRequest(http.MethodGet, api.URL+"/jobs", nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		if response, err := http.DefaultClient.Do(request); err != nil || response.StatusCode != expected {
+			t.Errorf("Token %q: expected %d, got %v (%v)", token, expected, response.StatusCode, err)
+		}
+	}
+
+	configureServe := func(args ...string) error {
+		options = downloader.Options{}
+		return parseCommand(findCommand("serve"), args)
+	}
+	if err := configureServe(); err != nil || !options.URLs.RejectPrivate || serveAddr != "localhost:8080" {
+		t.Errorf("Expected jobs to reject private addresses and the API to listen on localhost, got %v (%v, %s)", options.URLs.RejectPrivate, err, serveAddr)
+	}
+	if err := configureServe("--allow-private"); err != nil || options.URLs.RejectPrivate {
+		t.Errorf("Expected --allow-private to let jobs download private addresses, got %v", err)
+	}
+
+	base := downloader.Options{
+		BearerToken: "token",
+		BasicAuth:   &downloader.Credential{Login: "user"},
+		Netrc:       map[string]downloader.Credential{"": {Login: "default"}, "files.example.com": {Login: "files"}},
+	}
+	opts, _, _ := jobOptions{}.apply(base, time.Minute)
+	if _, ok := opts.Netrc["files.example.com"]; opts.BearerToken != "" || opts.BasicAuth != nil || len(opts.Netrc) != 1 || !ok {
+		t.Errorf("Expected jobs to keep only the netrc entries naming a host, got %+v", opts)
+	}
+	if _, ok := base.Netrc[""]; !ok {
+		t.Errorf("Expected the server netrc to be left as is")
+	}
+	base.AuthHosts = []string{"api.example.com"}
+	if opts, _, _ := (jobOptions{}).apply(base, time.Minute); opts.BearerToken != "token" || len(opts.Netrc) != 2 {
+		t.Errorf("Expected the credentials to be kept for the --auth-host hosts, got %+v", opts)
+	}
+}
@@ -28,18 +28,6 @@ func GetExeName() string {
 	return filepath.Base(executablePath)
 }
 
-// PrintAndDie prints an error message to stderr and exits with code 1.
-func PrintAndDie(msg string) {
-	fmt.Fprintln(os.Stderr, msg)
-	os.Exit(1)
-}
-
-// PrintHelpAndExit prints the usage instructions and exits with code 0.
-func PrintHelpAndExit() {
-	fmt.Println(usageStr)
-	os.Exit(0)
-}
-
 // PrintVersionAndExit prints the executable name and version, then exits with code 0.
 func PrintVersionAndExit(version string) {
 	fmt.Printf("%s: v%s\n", GetExeName(), version)
@@ -51,15 +39,40 @@ func getFileName(filePath string) string {
 	return strings.Split(filepath.Base(filePath), ".")[0]
 }
 
+// getOutputBase returns the directory used for logs, downloads and the
+// manifest of the given csv file, i.e. the file path without its extension.
+func getOutputBase(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath))
+}
+
+// manifestPath returns the manifest location for the given csv file path.
+func manifestPath(filePath string) string {
+	return filepath.Join(getOutputBase(filePath), "manifest.jsonl")
+}
+
+// rejectsPath returns the location of the rows rejected by Stage 1 for the given csv file path.
+func rejectsPath(filePath string) string {
+	return filepath.Join(getOutputBase(filePath), "rejects.csv")
+}
+
+// hostReportPath returns the host report location for the given csv file path.
+func hostReportPath(filePath string) string {
+	return filepath.Join(getOutputBase(filePath), "hosts.json")
+}
+
 // GetFileExtension extracts the file extension from the given file path.
 func GetFileExtension(filePath string) string {
 	ext := filepath.Ext(filePath)
 	return strings.TrimPrefix(ext, ".") // Remove the leading dot
 }
 
-func ensureScheme(url string) string {
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		return "https://" + url // Default to HTTPS
+// splitList splits a comma separated flag value, dropping empty items and surrounding spaces.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return url
+	return items
 }
@@ -0,0 +1,55 @@
+package src
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// validateFlags registers the flags of the validate command.
+func validateFlags(fs *flag.FlagSet) func(args []string) error {
+	fs.BoolVar(&jsonOutput, "json", false, "Print the invalid rows as JSON")
+	urlFlags(fs)
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected the csv or jsonl file to validate")
+		}
+		csvFilePath = args[0]
+		if !fileExists(csvFilePath) {
+			return fmt.Errorf("csv filepath is not found :%s", csvFilePath)
+		}
+		return checkOptions()
+	}
+}
+
+// runValidate reports the rows of csvFilePath that a download would skip or fail on.
+func runValidate() int {
+	rows, invalid, err := downloader.ValidateCSV(csvFilePath, options.URLs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", GetExeName(), csvFilePath, err)
+		return ExitError
+	}
+
+	if jsonOutput {
+		out := struct {
+			File    string                `json:"file"`
+			Rows    int                   `json:"rows"`
+			Invalid []downloader.RowError `json:"invalid"`
+		}{csvFilePath, rows, invalid}
+		if out.Invalid == nil {
+			out.Invalid = []downloader.RowError{}
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, row := range invalid {
+			fmt.Printf("%s:%d: %s: %q\n", csvFilePath, row.Line, row.Reason, row.Text)
+		}
+		fmt.Printf("%d rows, %d invalid\n", rows, len(invalid))
+	}
+	return checkExitCode(len(invalid), rows)
+}
@@ -0,0 +1,56 @@
+package src
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/garunkumar450/url-downloader/downloader"
+)
+
+// verifyFlags registers the flags of the verify command.
+func verifyFlags(fs *flag.FlagSet) func(args []string) error {
+	fs.BoolVar(&jsonOutput, "json", false, "Print the status of every file as JSON")
+	return manifestArg("verify")
+}
+
+// runVerify re-hashes the files saved by the run of manifestFile.
+//
+// Notes:
+// - Files recorded without a checksum are reported but don't fail the verification.
+func runVerify() int {
+	files, err := downloader.VerifyManifest(manifestFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+
+	counts := make(map[string]int)
+	for _, file := range files {
+		counts[file.Status]++
+	}
+	invalid := counts[downloader.VerifyMismatch] + counts[downloader.VerifyMissing]
+
+	if jsonOutput {
+		if files == nil {
+			files = []downloader.VerifiedFile{}
+		}
+		data, _ := json.MarshalIndent(files, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, file := range files {
+			if file.Status == downloader.VerifyOK {
+				continue
+			}
+			fmt.Printf("%s: %s (%s)", file.Status, file.Path, file.URL)
+			if file.Error != "" {
+				fmt.Printf(": %s", file.Error)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("%d files: %d ok, %d mismatched, %d missing, %d without checksum\n", len(files),
+			counts[downloader.VerifyOK], counts[downloader.VerifyMismatch], counts[downloader.VerifyMissing], counts[downloader.VerifyNoChecksum])
+	}
+	return checkExitCode(invalid, len(files)-counts[downloader.VerifyNoChecksum])
+}
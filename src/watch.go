@@ -0,0 +1,224 @@
+package src
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Subdirectories of the watched directory receiving the processed input files.
+const (
+	WATCH_DONE   = "done"   // the run succeeded, see ExitSuccess
+	WATCH_FAILED = "failed" // the run could not start, or failed beyond --max-failure-ratio
+)
+
+// watchFlags registers the flags of the watch command.
+func watchFlags(fs *flag.FlagSet) func(args []string) error {
+	fs.StringVar(&configFile, "config", os.Getenv(ENV_PREFIX+"CONFIG"), "YAML, TOML or JSON `file` setting any option by its long name (e.g. max-size: 1048576)")
+	fs.DurationVar(&runTimeout, "timeout", time.Hour, "Deadline of the run of every input file")
+	fs.StringVar(&logLevel, "log-level", zerolog.LevelDebugValue, "Log `level`: debug, info, warn or error")
+	fs.BoolVar(&watchPoll, "poll", false, "Poll the directory instead of using inotify, e.g. on a network file system")
+	fs.DurationVar(&watchInterval, "poll-interval", 2*time.Second, "Interval of the directory scans when polling")
+	fs.StringVar(&onPersisted, "on-persisted", "", "Shell `command` run for every saved file, with URL_DOWNLOADER_URL and URL_DOWNLOADER_PATH set")
+	configureOptions := optionFlags(fs)
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected the directory to watch")
+		}
+		watchDir = args[0]
+		if info, err := os.Stat(watchDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("watch directory is not found :%s", watchDir)
+		}
+		if err := configureOptions(); err != nil {
+			return err
+		}
+		if watchInterval <= 0 {
+			return invalidOption("poll-interval", "must be positive")
+		}
+		return validateOptions()
+	}
+}
+
+// runWatch downloads the input files dropped into the watched directory, one
+// at a time, until SIGINT/SIGTERM is received.
+//
+// Notes:
+// - The csv and jsonl files already in the directory are processed first; hidden files and other extensions are ignored.
+// - New files are seen with inotify once closed after writing or moved in, or by polling scans once their size and modification time stopped changing (--poll, or when inotify is unavailable).
+// - Every file runs through the download pipeline like `url-downloader <file>`: its downloads, manifest and rejected rows go to <file_without_extension>/ in the watched directory.
+// - The file is then moved to done/ or failed/ depending on the exit code of its run; a file interrupted by the shutdown stays in place and is processed again on the next start.
+// - The log goes to <dir>/<dir name>.log.
+func runWatch() int {
+	var err error
+	level, _ := zerolog.ParseLevel(logLevel) // validated by validateOptions
+	if err, zlog = initLogger(filepath.Clean(watchDir)+".log", true, level); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+		return ExitError
+	}
+	for _, name := range []string{WATCH_DONE, WATCH_FAILED} {
+		if err := os.MkdirAll(filepath.Join(watchDir, name), os.ModePerm); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", GetExeName(), err)
+			return ExitError
+		}
+	}
+	if onPersisted != "" {
+		options.Hooks = &commandHook{command: onPersisted}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	files := make(chan string)
+	go watchInputs(ctx, watchDir, files)
+	zlog.Info().Msgf("Watching %s for csv and jsonl files", watchDir)
+
+	stuck := make(map[string]bool) // files that could not be moved, not processed again
+	for {
+		select {
+		case file := <-files:
+			if stuck[file] || !fileExists(file) {
+				continue // seen twice, e.g. by the first scan and by inotify
+			}
+			if err := processInput(ctx, file); err != nil {
+				zlog.Error().Msgf("Error moving %s: %v", file, err)
+				stuck[file] = true
+			}
+		case <-ctx.Done():
+			zlog.Info().Msg("Shutdown initiated. Stopping the watch")
+			return ExitSuccess
+		}
+	}
+}
+
+// processInput downloads the URLs of file and moves it to done/ or failed/.
+//
+// Output:
+// - Returns an error if file could not be moved.
+//
+// Notes:
+// - The file stays in place if ctx is done before its run completes.
+func processInput(ctx context.Context, file string) error {
+	zlog.Info().Msgf("Processing %s", file)
+	csvFilePath, inputFiles = file, []string{file}
+	runCtx, cancel := context.WithTimeout(ctx, runTimeout)
+	result, err := runInputs(runCtx, ProgressOff)
+	cancel()
+	if ctx.Err() != nil {
+		zlog.Info().Msgf("Interrupted %s, leaving it for the next start", file)
+		return nil
+	}
+
+	target := WATCH_DONE
+	if err != nil {
+		zlog.Error().Msgf("Error processing %s: %v", file, err)
+		target = WATCH_FAILED
+	} else if ExitCode(result) != ExitSuccess {
+		target = WATCH_FAILED
+	}
+	moved, err := moveInput(file, filepath.Join(filepath.Dir(file), target))
+	if err != nil {
+		return err
+	}
+	zlog.Info().Str("Status", result.Status.String()).Msgf("Moved %s to %s", file, moved)
+	return nil
+}
+
+// moveInput moves file into dir and returns its new path. A file of the same
+// name already in dir is kept: the moved file gets a timestamp suffix instead,
+// e.g. list-20060102T150405.csv.
+func moveInput(file string, dir string) (string, error) {
+	target := filepath.Join(dir, filepath.Base(file))
+	if _, err := os.Stat(target); err == nil {
+		ext := filepath.Ext(file)
+		name := strings.TrimSuffix(filepath.Base(file), ext)
+		target = filepath.Join(dir, name+"-"+time.Now().Format("20060102T150405.000000000")+ext)
+	}
+	return target, os.Rename(file, target)
+}
+
+// watchInputs sends the input files appearing in dir to files until ctx is
+// done, with inotify unless --poll is set, falling back to polling.
+func watchInputs(ctx context.Context, dir string, files chan<- string) {
+	if !watchPoll {
+		err := inotifyInputs(ctx, dir, files)
+		if err == nil {
+			return
+		}
+		zlog.Warn().Msgf("Cannot watch %s with inotify: %v. Polling every %v instead", dir, err, watchInterval)
+	}
+	pollInputs(ctx, dir, watchInterval, files)
+}
+
+// pollInputs scans dir every interval and sends the input files whose size
+// and modification time did not change since the previous scan.
+func pollInputs(ctx context.Context, dir string, interval time.Duration, files chan<- string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	previous := make(map[string]os.FileInfo)
+	for {
+		current := make(map[string]os.FileInfo)
+		for _, file := range scanInputs(dir) {
+			info, err := os.Stat(file)
+			if err != nil {
+				continue // moved or removed since the scan
+			}
+			current[file] = info
+			if last, ok := previous[file]; ok && last.Size() == info.Size() && last.ModTime().Equal(info.ModTime()) {
+				if !sendInput(ctx, file, files) {
+					return
+				}
+			}
+		}
+		previous = current
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scanInputs returns the input files of dir, sorted by name.
+func scanInputs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		zlog.Error().Msgf("Error scanning %s: %v", dir, err)
+		return nil
+	}
+	var inputs []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isInputName(entry.Name()) {
+			inputs = append(inputs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// isInputName reports whether name is a csv or jsonl file that is not hidden,
+// so that files written as .list.csv.tmp and renamed once complete are skipped.
+func isInputName(name string) bool {
+	ext := GetFileExtension(name)
+	return !strings.HasPrefix(name, ".") && (ext == "csv" || ext == "jsonl")
+}
+
+// sendInput sends file to files, false if ctx is done first.
+func sendInput(ctx context.Context, file string, files chan<- string) bool {
+	select {
+	case files <- file:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package src
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyInputs sends the input files of dir, then the ones closed after
+// writing or moved into dir, until ctx is done.
+//
+// Output:
+// - Returns nil once ctx is done, an error if dir cannot be watched or the watch stops, e.g. dir was removed.
+//
+// Notes:
+// - When the kernel queue overflows, dir is scanned again so that no file is missed.
+func inotifyInputs(ctx context.Context, dir string, files chan<- string) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify_init1: %v", err)
+	}
+	// The watch is added before the first scan, a file dropped in between is sent twice at worst
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return fmt.Errorf("inotify_add_watch: %v", err)
+	}
+	// A non-blocking descriptor goes through the runtime poller, closing it ends a pending Read
+	events := os.NewFile(uintptr(fd), "inotify")
+	defer events.Close()
+	stop := context.AfterFunc(ctx, func() { events.Close() })
+	defer stop()
+
+	pending := scanInputs(dir)
+	buffer := make([]byte, 64*1024)
+	for {
+		for _, file := range pending {
+			if !sendInput(ctx, file, files) {
+				return nil
+			}
+		}
+		pending = pending[:0]
+
+		n, err := events.Read(buffer)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading events: %v", err)
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			switch {
+			case event.Mask&syscall.IN_Q_OVERFLOW != 0:
+				pending = scanInputs(dir)
+			case event.Mask&syscall.IN_IGNORED != 0:
+				return fmt.Errorf("%s is no longer watched", dir)
+			case isInputName(name):
+				pending = append(pending, filepath.Join(dir, name))
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package src
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test inotify sends the files already there, then the files written or moved in
+func TestInotifyInputs(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "first.csv"), []byte("url\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	files := make(chan string)
+	errs := make(chan error, 1)
+	go func() { errs <- inotifyInputs(ctx, dir, files) }()
+
+	receive := func(expected string) {
+		t.Helper()
+		select {
+		case file := <-files:
+			if file != filepath.Join(dir, expected) {
+				t.Errorf("Expected %s, got %s", filepath.Join(dir, expected), file)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected %s, got nothing", expected)
+		}
+	}
+	receive("first.csv")
+
+	os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("x\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "second.csv"), []byte("url\n"), 0644)
+	receive("second.csv")
+	os.WriteFile(filepath.Join(dir, ".third.tmp"), []byte("url\n"), 0644)
+	os.Rename(filepath.Join(dir, ".third.tmp"), filepath.Join(dir, "third.jsonl"))
+	receive("third.jsonl")
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Expected the watch to stop without error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the watch to stop once canceled")
+	}
+}
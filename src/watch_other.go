@@ -0,0 +1,13 @@
+//go:build !linux
+
+package src
+
+import (
+	"context"
+	"errors"
+)
+
+// inotifyInputs is only available on linux, the watch command polls elsewhere.
+func inotifyInputs(ctx context.Context, dir string, files chan<- string) error {
+	return errors.New("inotify is only available on linux")
+}
@@ -0,0 +1,66 @@
+package src
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test a processed file keeps the file of the same name already moved
+func TestMoveInput(t *testing.T) {
+	dir := t.TempDir()
+	done := filepath.Join(dir, WATCH_DONE)
+	os.Mkdir(done, os.ModePerm)
+	for i := 0; i < 2; i++ {
+		file := filepath.Join(dir, "list.csv")
+		if err := os.WriteFile(file, []byte("url\n"), 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		moved, err := moveInput(file, done)
+		if err != nil {
+			t.Fatalf("Failed to move input: %v", err)
+		}
+		if i == 0 && moved != filepath.Join(done, "list.csv") {
+			t.Errorf("Expected %s, got %s", filepath.Join(done, "list.csv"), moved)
+		}
+		if i == 1 && (!strings.HasPrefix(filepath.Base(moved), "list-") || filepath.Ext(moved) != ".csv") {
+			t.Errorf("Expected a timestamped list-*.csv, got %s", moved)
+		}
+	}
+	if entries, _ := os.ReadDir(done); len(entries) != 2 {
+		t.Errorf("Expected both files in %s, got %d", done, len(entries))
+	}
+}
+
+// Test polling sends the input files once they stop changing, and skips the hidden and other files
+func TestPollInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"list.csv", ".list.csv.tmp", "notes.txt", "more.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("url\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	files := make(chan string)
+	go pollInputs(ctx, dir, 10*time.Millisecond, files)
+
+	var received []string
+	for len(received) < 2 {
+		select {
+		case file := <-files:
+			received = append(received, file)
+			os.Remove(file) // processed
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected the input files, got %v", received)
+		}
+	}
+	expected := []string{filepath.Join(dir, "list.csv"), filepath.Join(dir, "more.jsonl")}
+	if strings.Join(received, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, received)
+	}
+}